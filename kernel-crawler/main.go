@@ -13,6 +13,8 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+
+	"github.com/stackrox/kernel-packer/tools/util"
 )
 
 func main() {
@@ -35,6 +37,7 @@ func mainCmd() error {
 		flagBaseURL          = flag.String("base-url", "", "repo base url")
 		flagBaseURLsFileJSON = flag.String("repos-file", "", "json file of repos, including name, base url and token")
 		flagReposNamesFile   = flag.String("repos-names-file", "", "file containing list of selected repo names to crawl from -repos-file")
+		flagArch             = flag.String("arch", util.DefaultArch, "comma separated list of package architectures to keep, besides noarch")
 	)
 	flag.Parse()
 
@@ -77,9 +80,14 @@ func mainCmd() error {
 		repoInfoByName = filteredRepoInfoByName
 	}
 
+	arches, err := parseArchFilter(*flagArch)
+	if err != nil {
+		return err
+	}
+
 	var urls []string
 	for _, repo := range repoInfoByName {
-		kernelUrls, err := getKernelURLs(client, strings.TrimSuffix(repo.Url, "/"), repo.Token)
+		kernelUrls, err := getKernelURLs(client, strings.TrimSuffix(repo.Url, "/"), repo.Token, arches)
 		if err != nil {
 			return err
 		}
@@ -94,7 +102,7 @@ func mainCmd() error {
 	return nil
 }
 
-func getKernelURLs(client *http.Client, baseURL string, token string) ([]string, error) {
+func getKernelURLs(client *http.Client, baseURL string, token string, arches archFilter) ([]string, error) {
 	// Contact the repo, and extract the url of the primary metadata archive.
 	primaryURL, err := getPrimaryURL(client, baseURL, token)
 	if err != nil {
@@ -102,7 +110,7 @@ func getKernelURLs(client *http.Client, baseURL string, token string) ([]string,
 	}
 
 	// Read the primary metadata archive, and extract all of the kernel-devel RPM package paths.
-	urls, err := getRPMURLs(client, baseURL, primaryURL, token)
+	urls, err := getRPMURLs(client, baseURL, primaryURL, token, arches)
 	if err != nil {
 		return nil, err
 	}
@@ -184,12 +192,13 @@ func getPrimaryURL(client *http.Client, baseURL string, authToken string) (strin
 
 type pkg struct {
 	Name     string `xml:"name"`
+	Arch     string `xml:"arch"`
 	Location struct {
 		Href string `xml:"href,attr"`
 	} `xml:"location"`
 }
 
-func getRPMURLs(client *http.Client, baseURL string, primaryURL string, authToken string) ([]string, error) {
+func getRPMURLs(client *http.Client, baseURL string, primaryURL string, authToken string, arches archFilter) ([]string, error) {
 	log.Printf("Fetching repo package metadata URL %s", primaryURL)
 	if authToken != "" {
 		primaryURL = primaryURL + "?" + authToken
@@ -236,6 +245,11 @@ func getRPMURLs(client *http.Client, baseURL string, primaryURL string, authToke
 					continue
 				}
 
+				// Only keep packages of the selected architectures.
+				if !arches.keep(pkg.Arch) {
+					continue
+				}
+
 				// Keep this kernel-devel RPM package url.
 				urls = append(urls, baseURL+"/"+pkg.Location.Href)
 			}
@@ -244,3 +258,40 @@ func getRPMURLs(client *http.Client, baseURL string, primaryURL string, authToke
 
 	return urls, nil
 }
+
+// archFilter is the set of kernel architectures, as named by util.NormalizeArch,
+// of the packages to keep.
+type archFilter map[string]struct{}
+
+// parseArchFilter parses a comma separated list of architectures, such as
+// "x86_64,arm64". Architectures can be given by any name that
+// util.NormalizeArch knows.
+func parseArchFilter(arches string) (archFilter, error) {
+	filter := make(archFilter)
+	for _, arch := range strings.Split(arches, ",") {
+		if arch = strings.TrimSpace(arch); arch == "" {
+			continue
+		}
+		normalized, found := util.NormalizeArch(arch)
+		if !found {
+			return nil, fmt.Errorf("unknown architecture %q", arch)
+		}
+		filter[normalized] = struct{}{}
+	}
+	return filter, nil
+}
+
+// keep reports whether packages of the given RPM architecture are kept.
+// Architecture independent packages, such as the SUSE kernel-devel packages,
+// are always kept.
+func (f archFilter) keep(arch string) bool {
+	if arch == "noarch" {
+		return true
+	}
+	normalized, found := util.NormalizeArch(arch)
+	if !found {
+		return false
+	}
+	_, found = f[normalized]
+	return found
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchFilter(t *testing.T) {
+	tests := []struct {
+		title    string
+		arches   string
+		kept     []string
+		dropped  []string
+		hasError bool
+	}{
+		{
+			title:   "default",
+			arches:  "x86_64",
+			kept:    []string{"x86_64", "noarch"},
+			dropped: []string{"aarch64", "s390x", "ppc64le", "src"},
+		},
+		{
+			title:   "alias",
+			arches:  "arm64",
+			kept:    []string{"aarch64", "noarch"},
+			dropped: []string{"x86_64"},
+		},
+		{
+			title:  "multiple",
+			arches: "amd64, aarch64,",
+			kept:   []string{"x86_64", "aarch64", "noarch"},
+		},
+		{
+			title:   "none",
+			arches:  "",
+			kept:    []string{"noarch"},
+			dropped: []string{"x86_64", "aarch64"},
+		},
+		{
+			title:    "unknown",
+			arches:   "x86_64,sparc",
+			hasError: true,
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			filter, err := parseArchFilter(test.arches)
+			if test.hasError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			for _, arch := range test.kept {
+				assert.True(t, filter.keep(arch), arch)
+			}
+			for _, arch := range test.dropped {
+				assert.False(t, filter.keep(arch), arch)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/stackrox/kernel-packer/tools/bundle"
+)
+
+func main() {
+	if err := mainCmd(); err != nil {
+		fmt.Fprintf(os.Stderr, "bundle-info: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+// bundleInfo is the information printed for a single bundle.
+type bundleInfo struct {
+	Path  string `json:"path"`
+	Error string `json:"error,omitempty"`
+
+	Meta     *bundle.Meta      `json:"meta,omitempty"`
+	Metadata *bundle.Metadata  `json:"metadata,omitempty"`
+	Features map[string]bool   `json:"features,omitempty"`
+	Helpers  []string          `json:"helpers,omitempty"`
+	Files    []string          `json:"files,omitempty"`
+	Config   map[string]string `json:"config,omitempty"`
+}
+
+func mainCmd() error {
+	var (
+		flagFiles   = flag.Bool("files", false, "Include the list of files in each bundle.")
+		flagConfig  = flag.Bool("config", true, "Include the kernel config options of each bundle.")
+		flagHelpers = flag.Bool("helpers", false, "Include the eBPF helpers provided by each bundle.")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] bundle.tgz...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return errors.New("no bundles given")
+	}
+
+	var (
+		infos  = make([]bundleInfo, 0, flag.NArg())
+		failed int
+	)
+
+	for _, filename := range flag.Args() {
+		info := bundleInfo{Path: filename}
+
+		b, err := bundle.Open(filename)
+		if err == nil {
+			info.Meta = &b.Meta
+			info.Metadata = b.Metadata
+			info.Features = b.Features()
+			if *flagHelpers {
+				info.Helpers = b.Helpers()
+			}
+			if *flagFiles {
+				info.Files = b.Files
+			}
+			if *flagConfig {
+				info.Config = b.Config
+			}
+			err = b.Validate()
+		}
+		if err != nil {
+			info.Error = err.Error()
+			failed++
+		}
+
+		infos = append(infos, info)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(infos); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return errors.Errorf("%d of %d bundles are invalid", failed, len(infos))
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/stackrox/kernel-packer/tools/bundle"
+)
+
+func main() {
+	if err := mainCmd(); err != nil {
+		fmt.Fprintf(os.Stderr, "bundle-meta: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+// mainCmd copies an uncompressed bundle tar archive from stdin to stdout, and
+// adds the given metadata to it, along with the kernel release and features
+// detected in the archive. It is run by the packer entrypoint, between tar
+// and the compression of the bundle.
+func mainCmd() error {
+	var (
+		flagMetadata = flag.String("metadata", "", "File containing the bundle metadata, as written by repackage-kernels.")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s -metadata bundle.json < bundle.tar > bundle-with-metadata.tar\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 0 || *flagMetadata == "" {
+		flag.Usage()
+		return errors.New("a metadata file, and no arguments, must be given")
+	}
+
+	metadata, err := bundle.ReadMetadata(*flagMetadata)
+	if err != nil {
+		return err
+	}
+
+	var (
+		in  = bufio.NewReader(os.Stdin)
+		out = bufio.NewWriter(os.Stdout)
+	)
+	if err := bundle.AddMetadata(out, in, metadata); err != nil {
+		return err
+	}
+	return out.Flush()
+}
@@ -0,0 +1,377 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// metaFiles are the meta files written into every bundle by the bundle_meta
+// function of the packer entrypoint, in the order that they are checked.
+var metaFiles = []string{
+	"BUNDLE_CHECKSUM",
+	"BUNDLE_DISTRO",
+	"BUNDLE_UNAME",
+	"BUNDLE_VERSION",
+	"BUNDLE_MAJOR",
+	"BUNDLE_MINOR",
+	"BUNDLE_BUILD_DIR",
+}
+
+// configFiles are the files that the kernel config is read from, in order of
+// preference. Some bundles have no .config, but do have the generated
+// auto.conf, which uses the same format.
+var configFiles = []string{
+	".config",
+	"include/config/auto.conf",
+}
+
+var (
+	unameRegex        = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)`)
+	configOptionRegex = regexp.MustCompile(`^(CONFIG_[A-Za-z0-9_]+)=(.*)$`)
+)
+
+// Meta is the meta information of a bundle.
+type Meta struct {
+	// Checksum is the id of the build that produced the bundle.
+	Checksum string `json:"checksum"`
+
+	// Distro is the distribution family of the kernel, such as "redhat".
+	Distro string `json:"distro"`
+
+	// Uname is the kernel release, as reported by uname -r.
+	Uname string `json:"uname"`
+
+	// Version, Major and Minor are the numeric components of the kernel
+	// release.
+	Version int `json:"version"`
+	Major   int `json:"major"`
+	Minor   int `json:"minor"`
+
+	// BuildDir is the path of the kernel build directory, relative to the
+	// root of the bundle.
+	BuildDir string `json:"buildDir"`
+}
+
+// Bundle is the contents of a kernel bundle archive.
+type Bundle struct {
+	Meta Meta `json:"meta"`
+
+	// Files are the paths of every file in the bundle, in sorted order. Paths
+	// are cleaned, so that "./build/.config" becomes "build/.config".
+	Files []string `json:"files"`
+
+	// Config holds the options that are set in the .config file of the
+	// kernel build directory, or in its auto.conf if it has no .config.
+	// Options that are not set are left out. Quoted string values are
+	// unquoted.
+	Config map[string]string `json:"config"`
+
+	// Metadata is the content of MetadataFile, or nil for bundles that were
+	// built without it.
+	Metadata *Metadata `json:"metadata,omitempty"`
+
+	// rawMeta holds the contents of every meta file that was found.
+	rawMeta map[string]string
+	files   map[string]struct{}
+
+	// contents holds the contents of the files that were asked for.
+	contents map[string][]byte
+}
+
+// Open reads the bundle archive at the given path, keeping the contents of
+// the files needed by the registered detectors. The archive is streamed once,
+// and fails to open if it is truncated or corrupt.
+func Open(filename string) (*Bundle, error) {
+	return OpenFiles(filename, DetectorFiles()...)
+}
+
+// OpenFiles reads the bundle archive at the given path, keeping the contents
+// of the given files, which are relative to the kernel build directory.
+func OpenFiles(filename string, files ...string) (*Bundle, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadFiles(file, files...)
+}
+
+// Read reads a gzipped bundle archive from the given reader, keeping the
+// contents of the files needed by the registered detectors.
+func Read(r io.Reader) (*Bundle, error) {
+	return ReadFiles(r, DetectorFiles()...)
+}
+
+// ReadFiles reads a gzipped bundle archive from the given reader, keeping the
+// contents of the given files, which are relative to the kernel build
+// directory.
+func ReadFiles(r io.Reader, files ...string) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read gzip header")
+	}
+
+	b, err := readArchive(tar.NewReader(gz), nil, files)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read past the end of the tar archive, so that the gzip checksum is
+	// verified.
+	if _, err := io.Copy(ioutil.Discard, gz); err != nil {
+		return nil, errors.Wrap(err, "truncated or corrupt archive")
+	}
+
+	if err := b.parse(); err != nil {
+		return nil, err
+	}
+
+	// Bundles that were built before the features were recorded in their
+	// metadata have them detected from the files that were kept.
+	if b.Metadata != nil && b.Metadata.Features == nil {
+		b.Metadata.Features = b.Features()
+		b.Metadata.Helpers = b.Helpers()
+	}
+
+	return b, nil
+}
+
+// readArchive reads every entry of the given tar archive, keeping the
+// contents of the given files, which are relative to the kernel build
+// directory. Every entry is also copied to the given writer, if not nil.
+func readArchive(tr *tar.Reader, tw *tar.Writer, files []string) (*Bundle, error) {
+	var b = &Bundle{
+		rawMeta:  make(map[string]string),
+		files:    make(map[string]struct{}),
+		contents: make(map[string][]byte),
+	}
+
+	// The kernel config is kept for every bundle.
+	files = append(files, configFiles...)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "truncated or corrupt archive")
+		}
+
+		var (
+			name   = path.Clean(header.Name)
+			isDir  = header.Typeflag == tar.TypeDir
+			isMeta = strings.HasPrefix(name, "BUNDLE_") && !strings.Contains(name, "/")
+			isJSON = name == MetadataFile
+			isKept = matchesAny(name, files)
+			buf    bytes.Buffer
+			dst    = ioutil.Discard
+		)
+
+		// The build directory is not known until BUNDLE_BUILD_DIR has been
+		// read, so the contents of every matching file are kept.
+		if !isDir && (isMeta || isJSON || isKept) {
+			dst = &buf
+		}
+		if tw != nil {
+			// The format of the header is chosen again when it is written.
+			header.Format = tar.FormatUnknown
+			if err := tw.WriteHeader(header); err != nil {
+				return nil, err
+			}
+			dst = io.MultiWriter(dst, tw)
+		}
+		if _, err := io.Copy(dst, tr); err != nil {
+			return nil, errors.Wrap(err, "truncated or corrupt archive")
+		}
+
+		switch {
+		case isDir:
+		case isMeta:
+			b.rawMeta[name] = strings.TrimSpace(buf.String())
+		case isJSON:
+			b.Metadata = new(Metadata)
+			if err := json.Unmarshal(buf.Bytes(), b.Metadata); err != nil {
+				return nil, errors.Wrapf(err, "invalid %s", MetadataFile)
+			}
+		default:
+			if isKept {
+				b.contents[name] = buf.Bytes()
+			}
+			b.files[name] = struct{}{}
+			b.Files = append(b.Files, name)
+		}
+	}
+
+	return b, nil
+}
+
+// parse fills in the meta information and the kernel config from the files
+// that were read.
+func (b *Bundle) parse() error {
+	sort.Strings(b.Files)
+
+	if err := b.parseMeta(); err != nil {
+		return err
+	}
+	for _, name := range configFiles {
+		if body, found := b.File(name); found {
+			b.Config = parseConfig(body)
+			break
+		}
+	}
+	if b.Config == nil {
+		b.Config = make(map[string]string)
+	}
+	return nil
+}
+
+// File returns the contents of the given file, relative to the kernel build
+// directory. Only files that were asked for when the bundle was read are
+// available. If the build directory does not hold the file, the first file in
+// the bundle with the same relative path is used instead, as the headers of
+// some distributions are split across several directories.
+func (b *Bundle) File(name string) ([]byte, bool) {
+	if body, found := b.contents[b.BuildPath(name)]; found {
+		return body, true
+	}
+
+	var matches []string
+	for candidate := range b.contents {
+		if strings.HasSuffix(candidate, "/"+path.Clean(name)) {
+			matches = append(matches, candidate)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, false
+	}
+	sort.Strings(matches)
+	return b.contents[matches[0]], true
+}
+
+// HasFile reports whether the bundle contains a file with the given path.
+func (b *Bundle) HasFile(name string) bool {
+	_, found := b.files[path.Clean(name)]
+	return found
+}
+
+// BuildPath returns the path of the given file, relative to the kernel build
+// directory, within the bundle.
+func (b *Bundle) BuildPath(name string) string {
+	return path.Join(b.Meta.BuildDir, name)
+}
+
+// Validate checks that every meta file is present and consistent with the
+// kernel release, that the metadata, if any, agrees with the meta files, and
+// that the kernel build directory contains a .config and Makefile.
+func (b *Bundle) Validate() error {
+	for _, name := range metaFiles {
+		if b.rawMeta[name] == "" {
+			return errors.Errorf("missing or empty meta file %s", name)
+		}
+	}
+
+	matches := unameRegex.FindStringSubmatch(b.Meta.Uname)
+	if matches == nil {
+		return errors.Errorf("BUNDLE_UNAME %q is not a kernel release", b.Meta.Uname)
+	}
+	for index, name := range []string{"BUNDLE_VERSION", "BUNDLE_MAJOR", "BUNDLE_MINOR"} {
+		if b.rawMeta[name] != matches[index+1] {
+			return errors.Errorf("%s %q does not match BUNDLE_UNAME %q", name, b.rawMeta[name], b.Meta.Uname)
+		}
+	}
+
+	if b.Metadata != nil {
+		if b.Metadata.ID != b.Meta.Checksum {
+			return errors.Errorf("%s id %q does not match BUNDLE_CHECKSUM %q", MetadataFile, b.Metadata.ID, b.Meta.Checksum)
+		}
+		if b.Metadata.Uname != b.Meta.Uname {
+			return errors.Errorf("%s uname %q does not match BUNDLE_UNAME %q", MetadataFile, b.Metadata.Uname, b.Meta.Uname)
+		}
+	}
+
+	for _, name := range []string{".config", "Makefile"} {
+		if !b.HasFile(b.BuildPath(name)) {
+			return errors.Errorf("missing %s", b.BuildPath(name))
+		}
+	}
+
+	return nil
+}
+
+// parseMeta fills in the typed meta information from the meta files.
+func (b *Bundle) parseMeta() error {
+	b.Meta = Meta{
+		Checksum: b.rawMeta["BUNDLE_CHECKSUM"],
+		Distro:   b.rawMeta["BUNDLE_DISTRO"],
+		Uname:    b.rawMeta["BUNDLE_UNAME"],
+		BuildDir: b.rawMeta["BUNDLE_BUILD_DIR"],
+	}
+
+	for _, part := range []struct {
+		name  string
+		value *int
+	}{
+		{name: "BUNDLE_VERSION", value: &b.Meta.Version},
+		{name: "BUNDLE_MAJOR", value: &b.Meta.Major},
+		{name: "BUNDLE_MINOR", value: &b.Meta.Minor},
+	} {
+		raw := b.rawMeta[part.name]
+		if raw == "" {
+			continue
+		}
+		number, err := strconv.Atoi(raw)
+		if err != nil {
+			return errors.Errorf("invalid %s %q", part.name, raw)
+		}
+		*part.value = number
+	}
+
+	return nil
+}
+
+// matchesAny reports whether the given path within a bundle is one of the
+// given paths, relative to any directory.
+func matchesAny(name string, files []string) bool {
+	for _, file := range files {
+		if name == file || strings.HasSuffix(name, "/"+file) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseConfig parses the set options of a kernel .config file.
+func parseConfig(body []byte) map[string]string {
+	config := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		matches := configOptionRegex.FindStringSubmatch(scanner.Text())
+		if matches == nil {
+			continue
+		}
+		value := matches[2]
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		config[matches[1]] = value
+	}
+
+	return config
+}
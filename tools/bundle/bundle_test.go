@@ -0,0 +1,220 @@
+package bundle
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stackrox/kernel-packer/tools/bundle/bundletest"
+)
+
+func testFiles() map[string]string {
+	return map[string]string{
+		"./BUNDLE_CHECKSUM":  "sha",
+		"./BUNDLE_DISTRO":    "coreos",
+		"./BUNDLE_UNAME":     "4.19.106-flatcar",
+		"./BUNDLE_VERSION":   "4",
+		"./BUNDLE_MAJOR":     "19",
+		"./BUNDLE_MINOR":     "106",
+		"./BUNDLE_BUILD_DIR": "./build",
+		"./build/.config": "# Automatically generated file; DO NOT EDIT.\n" +
+			"CONFIG_BPF=y\n" +
+			"CONFIG_BPF_SYSCALL=y\n" +
+			"# CONFIG_DEBUG_INFO_BTF is not set\n" +
+			"CONFIG_LOCALVERSION=\"-flatcar\"\n" +
+			"CONFIG_NR_CPUS=512\n",
+		"./build/Makefile":    "VERSION = 4\n",
+		"./source/.config":    "CONFIG_SOURCE=y\n",
+		"./source/Kbuild":     "",
+		"./build/include/a.h": "",
+	}
+}
+
+func TestRead(t *testing.T) {
+	b, err := Read(bytes.NewReader(bundletest.Archive(t, testFiles())))
+	require.NoError(t, err)
+	require.NoError(t, b.Validate())
+
+	assert.Equal(t, Meta{
+		Checksum: "sha",
+		Distro:   "coreos",
+		Uname:    "4.19.106-flatcar",
+		Version:  4,
+		Major:    19,
+		Minor:    106,
+		BuildDir: "./build",
+	}, b.Meta)
+	assert.Equal(t, []string{
+		"build/.config",
+		"build/Makefile",
+		"build/include/a.h",
+		"source/.config",
+		"source/Kbuild",
+	}, b.Files)
+	assert.Equal(t, map[string]string{
+		"CONFIG_BPF":          "y",
+		"CONFIG_BPF_SYSCALL":  "y",
+		"CONFIG_LOCALVERSION": "-flatcar",
+		"CONFIG_NR_CPUS":      "512",
+	}, b.Config)
+	assert.True(t, b.HasFile("./build/include/a.h"))
+	assert.False(t, b.HasFile("BUNDLE_UNAME"))
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		title    string
+		change   func(files map[string]string)
+		truncate bool
+		err      string
+	}{
+		{
+			title:    "truncated",
+			change:   func(files map[string]string) {},
+			truncate: true,
+			err:      "truncated or corrupt archive: unexpected EOF",
+		},
+		{
+			title:  "missing meta file",
+			change: func(files map[string]string) { delete(files, "./BUNDLE_DISTRO") },
+			err:    "missing or empty meta file BUNDLE_DISTRO",
+		},
+		{
+			title:  "invalid number",
+			change: func(files map[string]string) { files["./BUNDLE_MAJOR"] = "4.19" },
+			err:    `invalid BUNDLE_MAJOR "4.19"`,
+		},
+		{
+			title:  "inconsistent version",
+			change: func(files map[string]string) { files["./BUNDLE_MINOR"] = "107" },
+			err:    `BUNDLE_MINOR "107" does not match BUNDLE_UNAME "4.19.106-flatcar"`,
+		},
+		{
+			title:  "not a kernel release",
+			change: func(files map[string]string) { files["./BUNDLE_UNAME"] = "flatcar" },
+			err:    `BUNDLE_UNAME "flatcar" is not a kernel release`,
+		},
+		{
+			title:  "missing config",
+			change: func(files map[string]string) { delete(files, "./build/.config") },
+			err:    "missing build/.config",
+		},
+		{
+			title:  "missing makefile",
+			change: func(files map[string]string) { delete(files, "./build/Makefile") },
+			err:    "missing build/Makefile",
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			files := testFiles()
+			test.change(files)
+			body := bundletest.Archive(t, files)
+			if test.truncate {
+				body = body[:len(body)/2]
+			}
+
+			b, err := Read(bytes.NewReader(body))
+			if err == nil {
+				err = b.Validate()
+			}
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bundle")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	metadata := Metadata{
+		ID:        "sha",
+		Kind:      "coreos",
+		Packages:  []Package{{Name: "flatcar_developer_container.bin.bz2", SHA256: "0000"}},
+		Image:     "repackage:latest",
+		BuildTime: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+		Uname:     "4.19.106-flatcar",
+	}
+
+	filename := filepath.Join(dir, MetadataFile)
+	require.NoError(t, WriteMetadata(filename, metadata))
+
+	info, err := os.Stat(filename)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	body, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+
+	files := testFiles()
+	files["./"+MetadataFile] = string(body)
+	b, err := Read(bytes.NewReader(bundletest.Archive(t, files)))
+	require.NoError(t, err)
+	require.NoError(t, b.Validate())
+
+	metadata.Features = map[string]bool{
+		"btf":              false,
+		"bpf_ringbuf_map":  false,
+		"core_relocations": false,
+		"fentry_fexit":     false,
+		"lsm_bpf":          false,
+		"bpf_loop":         false,
+	}
+	assert.Equal(t, &metadata, b.Metadata)
+	assert.NotContains(t, b.Files, MetadataFile)
+	assert.Len(t, b.Files, 5)
+}
+
+func TestAddMetadata(t *testing.T) {
+	files := testFiles()
+	files["./build/include/uapi/linux/bpf.h"] = "#define __BPF_FUNC_MAPPER(FN) FN(unspec), FN(map_lookup_elem), FN(ringbuf_output),\n" +
+		"enum bpf_map_type { BPF_MAP_TYPE_RINGBUF };\n"
+
+	gz, err := gzip.NewReader(bytes.NewReader(bundletest.Archive(t, files)))
+	require.NoError(t, err)
+
+	metadata := Metadata{
+		ID:        "sha",
+		Kind:      "coreos",
+		Packages:  []Package{{Name: "flatcar_developer_container.bin.bz2", SHA256: "0000"}},
+		Image:     "repackage:latest",
+		BuildTime: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	var archive bytes.Buffer
+	require.NoError(t, AddMetadata(&archive, gz, metadata))
+
+	var compressed bytes.Buffer
+	gzw := gzip.NewWriter(&compressed)
+	_, err = gzw.Write(archive.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, gzw.Close())
+
+	b, err := Read(&compressed)
+	require.NoError(t, err)
+	require.NoError(t, b.Validate())
+
+	metadata.Uname = "4.19.106-flatcar"
+	metadata.Features = map[string]bool{
+		"btf":              false,
+		"bpf_ringbuf_map":  true,
+		"core_relocations": false,
+		"fentry_fexit":     false,
+		"lsm_bpf":          false,
+		"bpf_loop":         false,
+	}
+	metadata.Helpers = []string{"bpf_map_lookup_elem", "bpf_ringbuf_output"}
+	assert.Equal(t, &metadata, b.Metadata)
+	assert.Len(t, b.Files, 6)
+}
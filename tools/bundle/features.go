@@ -0,0 +1,307 @@
+package bundle
+
+import (
+	"regexp"
+	"sort"
+	"sync"
+)
+
+const (
+	// bpfHeader is the header that lists the eBPF helpers, map types,
+	// program types and attach types.
+	bpfHeader = "include/uapi/linux/bpf.h"
+
+	// btfHeader is the header that lists the BTF kinds.
+	btfHeader = "include/uapi/linux/btf.h"
+)
+
+// helperRegex matches the eBPF helpers listed by the __BPF_FUNC_MAPPER macro.
+// Helpers are listed as FN(name) in older kernels, and as FN(name, id, ##ctx)
+// since 6.1.
+var helperRegex = regexp.MustCompile(`\bFN\(\s*([a-z0-9_]+)\s*[,)]`)
+
+// Detector detects whether a kernel feature is available in a bundle.
+type Detector interface {
+	// Feature is the name of the feature, as used in kernel-features.json.
+	Feature() string
+
+	// Files are the files, relative to the kernel build directory, whose
+	// contents the detector needs. They must be asked for when the bundle is
+	// read.
+	Files() []string
+
+	// Detect reports whether the feature is available.
+	Detect(b *Bundle) bool
+}
+
+var (
+	detectorsMutex sync.RWMutex
+	detectors      = make(map[string]Detector)
+)
+
+// Register adds the given detector to the registry, replacing any detector
+// for the same feature.
+func Register(detector Detector) {
+	detectorsMutex.Lock()
+	defer detectorsMutex.Unlock()
+
+	detectors[detector.Feature()] = detector
+}
+
+// Detectors returns every registered detector, sorted by feature.
+func Detectors() []Detector {
+	detectorsMutex.RLock()
+	defer detectorsMutex.RUnlock()
+
+	var results = make([]Detector, 0, len(detectors))
+	for _, detector := range detectors {
+		results = append(results, detector)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Feature() < results[j].Feature()
+	})
+	return results
+}
+
+// DetectorFiles returns the files needed by every registered detector.
+func DetectorFiles() []string {
+	var (
+		seen  = make(map[string]struct{})
+		files []string
+	)
+	for _, detector := range Detectors() {
+		for _, file := range detector.Files() {
+			if _, found := seen[file]; !found {
+				seen[file] = struct{}{}
+				files = append(files, file)
+			}
+		}
+	}
+	return files
+}
+
+// Features returns every kernel feature that is detected in the bundle by the
+// registered detectors.
+func (b *Bundle) Features() map[string]bool {
+	var features = make(map[string]bool)
+	for _, detector := range Detectors() {
+		features[detector.Feature()] = detector.Detect(b)
+	}
+	return features
+}
+
+// KconfigDetector detects a feature that is enabled by a kernel config option.
+type KconfigDetector struct {
+	Name string
+
+	// Option is the name of the config option, such as
+	// "CONFIG_DEBUG_INFO_BTF".
+	Option string
+
+	// Values are the option values that enable the feature. If empty, the
+	// option must be set to "y".
+	Values []string
+}
+
+// Feature implements Detector.
+func (d KconfigDetector) Feature() string {
+	return d.Name
+}
+
+// Files implements Detector. The kernel config is read for every bundle.
+func (d KconfigDetector) Files() []string {
+	return nil
+}
+
+// Detect implements Detector.
+func (d KconfigDetector) Detect(b *Bundle) bool {
+	var (
+		value, found = b.Config[d.Option]
+		values       = d.Values
+	)
+	if !found {
+		return false
+	}
+	if len(values) == 0 {
+		values = []string{"y"}
+	}
+	for _, expected := range values {
+		if value == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// SymbolDetector detects a feature by the presence of a symbol, such as an
+// enum value or a macro, in a kernel header. SymbolDetectors are made with
+// NewSymbolDetector, so that the search is compiled once.
+type SymbolDetector struct {
+	Name string
+
+	// File is the header to search, relative to the kernel build directory,
+	// such as "include/uapi/linux/bpf.h".
+	File string
+
+	// Symbol is the identifier to search for.
+	Symbol string
+
+	regex *regexp.Regexp
+}
+
+// NewSymbolDetector returns a SymbolDetector for the given feature, that
+// searches the given file for the given symbol.
+func NewSymbolDetector(name string, file string, symbol string) SymbolDetector {
+	return SymbolDetector{
+		Name:   name,
+		File:   file,
+		Symbol: symbol,
+		regex:  symbolRegex(symbol),
+	}
+}
+
+// symbolRegex returns a regular expression that matches the given symbol as a
+// whole word.
+func symbolRegex(symbol string) *regexp.Regexp {
+	return regexp.MustCompile(`\b` + regexp.QuoteMeta(symbol) + `\b`)
+}
+
+// Feature implements Detector.
+func (d SymbolDetector) Feature() string {
+	return d.Name
+}
+
+// Files implements Detector.
+func (d SymbolDetector) Files() []string {
+	return []string{d.File}
+}
+
+// Detect implements Detector. The search is compiled here only if the
+// detector was not made with NewSymbolDetector.
+func (d SymbolDetector) Detect(b *Bundle) bool {
+	body, found := b.File(d.File)
+	if !found {
+		return false
+	}
+	regex := d.regex
+	if regex == nil {
+		regex = symbolRegex(d.Symbol)
+	}
+	return regex.Match(body)
+}
+
+// HelperDetector detects a feature by the availability of an eBPF helper
+// function, as listed by the __BPF_FUNC_MAPPER macro of the uapi bpf.h
+// header.
+type HelperDetector struct {
+	Name string
+
+	// Helper is the name of the helper, without its bpf_ prefix, such as
+	// "ringbuf_output".
+	Helper string
+}
+
+// Feature implements Detector.
+func (d HelperDetector) Feature() string {
+	return d.Name
+}
+
+// Files implements Detector.
+func (d HelperDetector) Files() []string {
+	return []string{bpfHeader}
+}
+
+// Detect implements Detector.
+func (d HelperDetector) Detect(b *Bundle) bool {
+	for _, helper := range b.Helpers() {
+		if helper == "bpf_"+d.Helper {
+			return true
+		}
+	}
+	return false
+}
+
+// AllDetector detects a feature that needs every one of the given detectors to
+// detect their feature.
+type AllDetector struct {
+	Name      string
+	Detectors []Detector
+}
+
+// Feature implements Detector.
+func (d AllDetector) Feature() string {
+	return d.Name
+}
+
+// Files implements Detector.
+func (d AllDetector) Files() []string {
+	var files []string
+	for _, detector := range d.Detectors {
+		files = append(files, detector.Files()...)
+	}
+	return files
+}
+
+// Detect implements Detector.
+func (d AllDetector) Detect(b *Bundle) bool {
+	for _, detector := range d.Detectors {
+		if !detector.Detect(b) {
+			return false
+		}
+	}
+	return true
+}
+
+// Helpers returns the names of every eBPF helper function listed in the uapi
+// bpf.h header of the bundle, in the order of the helper enum, such as
+// "bpf_map_lookup_elem".
+func (b *Bundle) Helpers() []string {
+	body, found := b.File(bpfHeader)
+	if !found {
+		return nil
+	}
+
+	var helpers []string
+	for _, matches := range helperRegex.FindAllSubmatch(body, -1) {
+		if name := string(matches[1]); name != "unspec" {
+			helpers = append(helpers, "bpf_"+name)
+		}
+	}
+	return helpers
+}
+
+// The capabilities that are detected in every bundle. Detection relies only on
+// the uapi headers and Kconfig of the bundle, and never on the kernel version,
+// as vendor kernels backport BPF features to older versions.
+func init() {
+	var btf = KconfigDetector{Name: "btf", Option: "CONFIG_DEBUG_INFO_BTF"}
+
+	Register(btf)
+	Register(NewSymbolDetector("bpf_ringbuf_map", bpfHeader, "BPF_MAP_TYPE_RINGBUF"))
+	// CO-RE relocations are resolved against the kernel BTF, which must
+	// describe functions as well as types.
+	Register(AllDetector{
+		Name: "core_relocations",
+		Detectors: []Detector{
+			btf,
+			NewSymbolDetector("", btfHeader, "BTF_KIND_FUNC"),
+		},
+	})
+	// fentry and fexit programs are attached by their BTF id.
+	Register(AllDetector{
+		Name: "fentry_fexit",
+		Detectors: []Detector{
+			btf,
+			NewSymbolDetector("", bpfHeader, "BPF_TRACE_FENTRY"),
+			NewSymbolDetector("", bpfHeader, "BPF_TRACE_FEXIT"),
+		},
+	})
+	Register(AllDetector{
+		Name: "lsm_bpf",
+		Detectors: []Detector{
+			KconfigDetector{Option: "CONFIG_BPF_LSM"},
+			NewSymbolDetector("", bpfHeader, "BPF_PROG_TYPE_LSM"),
+		},
+	})
+	Register(HelperDetector{Name: "bpf_loop", Helper: "loop"})
+}
@@ -0,0 +1,259 @@
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stackrox/kernel-packer/tools/bundle/bundletest"
+)
+
+const (
+	oldBPFHeader = `enum bpf_map_type {
+	BPF_MAP_TYPE_UNSPEC,
+	BPF_MAP_TYPE_HASH,
+};
+
+#define __BPF_FUNC_MAPPER(FN)		\
+	FN(unspec),			\
+	FN(map_lookup_elem),		\
+	FN(get_current_task),
+`
+
+	newBPFHeader = `enum bpf_map_type {
+	BPF_MAP_TYPE_UNSPEC,
+	BPF_MAP_TYPE_RINGBUF,
+	BPF_MAP_TYPE_RINGBUF_USER,
+};
+
+#define ___BPF_FUNC_MAPPER(FN, ctx...)			\
+	FN(unspec, 0, ##ctx)				\
+	FN(ringbuf_output, 130, ##ctx)			\
+	FN(loop, 181, ##ctx)				\
+`
+)
+
+func TestDetectors(t *testing.T) {
+	tests := []struct {
+		title    string
+		files    map[string]string
+		detector Detector
+		expected bool
+	}{
+		{
+			title:    "kconfig set",
+			detector: KconfigDetector{Name: "bpf", Option: "CONFIG_BPF"},
+			expected: true,
+		},
+		{
+			title:    "kconfig not set",
+			detector: KconfigDetector{Name: "btf", Option: "CONFIG_DEBUG_INFO_BTF"},
+			expected: false,
+		},
+		{
+			title:    "kconfig value",
+			detector: KconfigDetector{Name: "cpus", Option: "CONFIG_NR_CPUS", Values: []string{"256", "512"}},
+			expected: true,
+		},
+		{
+			title:    "symbol in build dir",
+			files:    map[string]string{"./build/include/uapi/linux/bpf.h": newBPFHeader},
+			detector: NewSymbolDetector("ringbuf", bpfHeader, "BPF_MAP_TYPE_RINGBUF"),
+			expected: true,
+		},
+		{
+			title:    "symbol in source dir",
+			files:    map[string]string{"./source/include/uapi/linux/bpf.h": newBPFHeader},
+			detector: NewSymbolDetector("ringbuf", bpfHeader, "BPF_MAP_TYPE_RINGBUF"),
+			expected: true,
+		},
+		{
+			title:    "symbol missing",
+			files:    map[string]string{"./build/include/uapi/linux/bpf.h": oldBPFHeader},
+			detector: NewSymbolDetector("ringbuf", bpfHeader, "BPF_MAP_TYPE_RINGBUF"),
+			expected: false,
+		},
+		{
+			title:    "symbol prefix does not match",
+			files:    map[string]string{"./build/include/uapi/linux/bpf.h": newBPFHeader},
+			detector: NewSymbolDetector("ringbuf", bpfHeader, "BPF_MAP_TYPE_RING"),
+			expected: false,
+		},
+		{
+			title:    "symbol header missing",
+			detector: NewSymbolDetector("ringbuf", bpfHeader, "BPF_MAP_TYPE_RINGBUF"),
+			expected: false,
+		},
+		{
+			title:    "helper in old header",
+			files:    map[string]string{"./build/include/uapi/linux/bpf.h": oldBPFHeader},
+			detector: HelperDetector{Name: "task", Helper: "get_current_task"},
+			expected: true,
+		},
+		{
+			title:    "helper in new header",
+			files:    map[string]string{"./build/include/uapi/linux/bpf.h": newBPFHeader},
+			detector: HelperDetector{Name: "loop", Helper: "loop"},
+			expected: true,
+		},
+		{
+			title:    "helper missing",
+			files:    map[string]string{"./build/include/uapi/linux/bpf.h": oldBPFHeader},
+			detector: HelperDetector{Name: "loop", Helper: "loop"},
+			expected: false,
+		},
+		{
+			title: "all detected",
+			files: map[string]string{"./build/include/uapi/linux/bpf.h": newBPFHeader},
+			detector: AllDetector{Name: "all", Detectors: []Detector{
+				KconfigDetector{Option: "CONFIG_BPF_SYSCALL"},
+				HelperDetector{Helper: "ringbuf_output"},
+			}},
+			expected: true,
+		},
+		{
+			title: "not all detected",
+			files: map[string]string{"./build/include/uapi/linux/bpf.h": newBPFHeader},
+			detector: AllDetector{Name: "all", Detectors: []Detector{
+				KconfigDetector{Option: "CONFIG_DEBUG_INFO_BTF"},
+				HelperDetector{Helper: "ringbuf_output"},
+			}},
+			expected: false,
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			files := testFiles()
+			for name, body := range test.files {
+				files[name] = body
+			}
+
+			b, err := ReadFiles(bytes.NewReader(bundletest.Archive(t, files)), test.detector.Files()...)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, test.detector.Detect(b))
+		})
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	tests := []struct {
+		title    string
+		files    map[string]string
+		expected map[string]bool
+	}{
+		{
+			title: "old kernel",
+			files: map[string]string{
+				"./source/include/uapi/linux/bpf.h": oldBPFHeader,
+			},
+			expected: map[string]bool{},
+		},
+		{
+			title: "no headers",
+			files: map[string]string{
+				"./BUNDLE_UNAME":   "5.10.0-flatcar",
+				"./BUNDLE_VERSION": "5",
+				"./BUNDLE_MAJOR":   "10",
+				"./BUNDLE_MINOR":   "0",
+			},
+			expected: map[string]bool{},
+		},
+		{
+			// A vendor kernel with an old version, and backported features.
+			title: "backported",
+			files: map[string]string{
+				"./build/.config": "CONFIG_BPF_SYSCALL=y\n" +
+					"CONFIG_DEBUG_INFO_BTF=y\n" +
+					"CONFIG_BPF_LSM=y\n",
+				"./build/include/uapi/linux/bpf.h": newBPFHeader +
+					"enum bpf_prog_type { BPF_PROG_TYPE_LSM, };\n" +
+					"enum bpf_attach_type { BPF_TRACE_FENTRY, BPF_TRACE_FEXIT, };\n",
+				"./build/include/uapi/linux/btf.h": "#define BTF_KIND_FUNC 12\n",
+			},
+			expected: map[string]bool{
+				"btf":              true,
+				"bpf_ringbuf_map":  true,
+				"core_relocations": true,
+				"fentry_fexit":     true,
+				"lsm_bpf":          true,
+				"bpf_loop":         true,
+			},
+		},
+		{
+			title: "btf without lsm",
+			files: map[string]string{
+				"./build/.config":                  "CONFIG_DEBUG_INFO_BTF=y\n",
+				"./build/include/uapi/linux/bpf.h": newBPFHeader + "BPF_PROG_TYPE_LSM BPF_TRACE_FENTRY\n",
+				"./build/include/uapi/linux/btf.h": "#define BTF_KIND_FUNC 12\n",
+			},
+			expected: map[string]bool{
+				"btf":              true,
+				"bpf_ringbuf_map":  true,
+				"core_relocations": true,
+				"bpf_loop":         true,
+			},
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			files := testFiles()
+			for name, body := range test.files {
+				files[name] = body
+			}
+
+			b, err := Read(bytes.NewReader(bundletest.Archive(t, files)))
+			require.NoError(t, err)
+
+			expected := map[string]bool{
+				"btf":              false,
+				"bpf_ringbuf_map":  false,
+				"core_relocations": false,
+				"fentry_fexit":     false,
+				"lsm_bpf":          false,
+				"bpf_loop":         false,
+			}
+			for feature, value := range test.expected {
+				expected[feature] = value
+			}
+			assert.Equal(t, expected, b.Features())
+		})
+	}
+}
+
+func TestHelpers(t *testing.T) {
+	tests := []struct {
+		title    string
+		header   string
+		expected []string
+	}{
+		{
+			title:    "old header",
+			header:   oldBPFHeader,
+			expected: []string{"bpf_map_lookup_elem", "bpf_get_current_task"},
+		},
+		{
+			title:    "new header",
+			header:   newBPFHeader,
+			expected: []string{"bpf_ringbuf_output", "bpf_loop"},
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			files := testFiles()
+			files["./build/include/uapi/linux/bpf.h"] = test.header
+
+			b, err := Read(bytes.NewReader(bundletest.Archive(t, files)))
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, b.Helpers())
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package bundle
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MetadataFile is the name of the file, at the root of a bundle, that holds
+// the bundle Metadata.
+const MetadataFile = "bundle.json"
+
+// Metadata records where a bundle came from, and what it contains.
+type Metadata struct {
+	// ID is the manifest id of the build that produced the bundle.
+	ID string `json:"id"`
+
+	// Kind is the manifest kind of the build, such as "redhat".
+	Kind string `json:"kind"`
+
+	// Arch is the kernel architecture of the build, such as "x86_64". It is
+	// empty in bundles that were built before it was recorded.
+	Arch string `json:"arch,omitempty"`
+
+	// Packages are the kernel packages that the bundle was built from.
+	Packages []Package `json:"packages"`
+
+	// Image is the packer image that the bundle was built with.
+	Image string `json:"image"`
+
+	// BuildTime is when the bundle was built.
+	BuildTime time.Time `json:"buildTime"`
+
+	// Uname is the kernel release of the bundle, as in BUNDLE_UNAME. It is
+	// filled in by AddMetadata.
+	Uname string `json:"uname"`
+
+	// Features are the kernel features detected in the bundle. They are
+	// filled in by AddMetadata, or detected when the bundle is read, for
+	// bundles that were built before they were recorded.
+	Features map[string]bool `json:"features,omitempty"`
+
+	// Helpers are the eBPF helper functions that the kernel provides. They
+	// are filled in as Features are.
+	Helpers []string `json:"helpers,omitempty"`
+}
+
+// Package is a single kernel package that a bundle was built from.
+type Package struct {
+	// Name is the simplified name of the package, as used in the manifest
+	// and in the package bucket.
+	Name string `json:"name"`
+
+	// URL is the URL that the package was crawled from, if known.
+	URL string `json:"url,omitempty"`
+
+	// SHA256 is the checksum of the package file.
+	SHA256 string `json:"sha256"`
+}
+
+// WriteMetadata writes the given metadata as JSON to the given file. The packer
+// adds it to the bundle with AddMetadata.
+func WriteMetadata(filename string, metadata Metadata) error {
+	body, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, body, 0644)
+}
+
+// ReadMetadata reads metadata that was written by WriteMetadata from the given
+// file.
+func ReadMetadata(filename string) (Metadata, error) {
+	var metadata Metadata
+
+	body, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return metadata, err
+	}
+	if err := json.Unmarshal(body, &metadata); err != nil {
+		return metadata, errors.Wrapf(err, "invalid %s", filename)
+	}
+	return metadata, nil
+}
+
+// AddMetadata copies the uncompressed bundle tar archive from the given reader
+// to the given writer, and adds the given metadata to it as MetadataFile. The
+// kernel release and the kernel features of the metadata are filled in from
+// the archive as it is copied, so that the bundle is only compressed once.
+func AddMetadata(w io.Writer, r io.Reader, metadata Metadata) error {
+	tw := tar.NewWriter(w)
+
+	b, err := readArchive(tar.NewReader(r), tw, DetectorFiles())
+	if err != nil {
+		return err
+	}
+	if err := b.parse(); err != nil {
+		return err
+	}
+
+	metadata.Uname = b.Meta.Uname
+	metadata.Features = b.Features()
+	metadata.Helpers = b.Helpers()
+
+	body, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{
+		Name:     "./" + MetadataFile,
+		Mode:     0644,
+		Size:     int64(len(body)),
+		ModTime:  time.Now(),
+		Typeflag: tar.TypeReg,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := tw.Write(body); err != nil {
+		return err
+	}
+	return tw.Close()
+}
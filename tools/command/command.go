@@ -1,82 +1,103 @@
 package command
 
 import (
-	"errors"
-	"fmt"
+	"context"
 	"io"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"syscall"
 )
 
 // Run will exec the given command and stream all output (stdout and stderr)
 // back to the current terminal.
 func Run(name string, arg ...string) error {
-	cmd := exec.Command(name, arg...)
+	return RunOutput(os.Stdout, os.Stderr, name, arg...)
+}
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return err
-	}
+// RunOutput will exec the given command and stream its stdout and stderr to
+// the given writers. The same writer may be used for both streams.
+func RunOutput(stdout io.Writer, stderr io.Writer, name string, arg ...string) error {
+	return RunContext(context.Background(), stdout, stderr, name, arg...)
+}
 
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return err
+// RunContext will exec the given command and stream its stdout and stderr to
+// the given writers. The process is killed if the context is done before the
+// command completes, in which case the context error is returned.
+func RunContext(ctx context.Context, stdout io.Writer, stderr io.Writer, name string, arg ...string) error {
+	cmd := exec.CommandContext(ctx, name, arg...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return ctx.Err()
 	}
-
-	go io.Copy(os.Stdout, stdout)
-	go io.Copy(os.Stderr, stderr)
-
-	return cmd.Run()
+	return err
 }
 
-func DockerCommand(image string, checksum string, distroName string, outputDir string, packages []string) (string, []string, error) {
-	var cmd = "docker"
-	var args = []string{
-		"run",
-		"--privileged",
-		"--rm",
-		"-t",
+// RunGroupContext is like RunContext, but runs the command in a process group
+// of its own, and kills the whole group if the context is done before the
+// command completes, so that no children of the command are left running. The
+// given started function, if any, is called with the process group id once the
+// command has started, and the given stopped function once it has completed.
+func RunGroupContext(ctx context.Context, stdout io.Writer, stderr io.Writer, started func(pgid int), stopped func(), name string, arg ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	if len(image) == 0 {
-		image = "repackage:latest"
-	}
+	cmd := exec.Command(name, arg...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
-	if len(packages) == 0 {
-		return "", nil, errors.New("no packages given")
+	if err := cmd.Start(); err != nil {
+		return err
 	}
 
-	if !filepath.IsAbs(outputDir) {
-		return "", nil, errors.New("output directory is not an absolute path")
+	// The process group id is the process id of the group leader.
+	pgid := cmd.Process.Pid
+	if started != nil {
+		started(pgid)
 	}
 
-	pkgDir := filepath.Dir(packages[0])
-	for _, pkg := range packages {
-		if !filepath.IsAbs(pkg) {
-			return "", nil, errors.New("package is not an absolute path")
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = KillGroup(pgid)
+		case <-done:
 		}
-		if pkgDir != filepath.Dir(pkg) {
-			return "", nil, errors.New("packages are not all in the same directory")
-		}
-	}
-
-	// Add host mount for loopback device mounting
-	args = append(args, "-v", "/dev:/dev")
+	}()
 
-	// Add a read-only volume mapping for directory containing the given packages.
-	args = append(args, "-v", fmt.Sprintf("%s:/input:ro", pkgDir))
-
-	// Add a single volume mapping for the output directory.
-	args = append(args, "-v", fmt.Sprintf("%s:/output", outputDir))
+	err := cmd.Wait()
+	close(done)
+	if stopped != nil {
+		stopped()
+	}
 
-	// Add the Docker image name, distro name, and output directory alias
-	args = append(args, image, checksum, distroName, "/output")
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
 
-	// Add a series of package names, same as the volume aliases.
-	for _, pkg := range packages {
-		args = append(args, fmt.Sprintf("/input/%s", filepath.Base(pkg)))
+// KillGroup kills every process in the given process group. It is not an
+// error if the group no longer exists.
+func KillGroup(pgid int) error {
+	if err := syscall.Kill(-pgid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		return err
 	}
+	return nil
+}
 
-	return cmd, args, nil
+// ContainerName returns the name given to the packer container that builds
+// the bundle with the given checksum.
+func ContainerName(checksum string) string {
+	return "repackage-" + checksum
+}
+
+// DockerCommand returns the docker command line that repackages the given
+// packages into a bundle inside of outputDir.
+func DockerCommand(image string, checksum string, distroName string, outputDir string, packages []string) (string, []string, error) {
+	return runtimes["docker"].Command(image, checksum, distroName, outputDir, packages)
 }
@@ -1,8 +1,15 @@
 package command
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -47,6 +54,7 @@ func Test(t *testing.T) {
 			packages:  []string{"/package.rpm"},
 			expectedArgs: []string{
 				"run", "--privileged", "--rm", "-t",
+				"--name", "repackage-sha",
 				"-v", "/dev:/dev",
 				"-v", "/:/input:ro",
 				"-v", "/.build-data/bundles:/output",
@@ -65,6 +73,7 @@ func Test(t *testing.T) {
 			},
 			expectedArgs: []string{
 				"run", "--privileged", "--rm", "-t",
+				"--name", "repackage-sha",
 				"-v", "/dev:/dev",
 				"-v", "/:/input:ro",
 				"-v", "/.build-data/bundles:/output",
@@ -80,7 +89,7 @@ func Test(t *testing.T) {
 		name := fmt.Sprintf("%d %s", index+1, test.title)
 		t.Run(name, func(t *testing.T) {
 
-			actualCmd, actualArgs, actualErr := DockerCommand(test.checksum, test.distro, test.outputDir, test.packages)
+			actualCmd, actualArgs, actualErr := DockerCommand("", test.checksum, test.distro, test.outputDir, test.packages)
 
 			if test.err != "" {
 				require.EqualError(t, actualErr, test.err)
@@ -95,3 +104,203 @@ func Test(t *testing.T) {
 		})
 	}
 }
+
+func TestRuntimes(t *testing.T) {
+	tests := []struct {
+		title        string
+		runtime      string
+		outputDir    string
+		packages     []string
+		err          string
+		expectedCmd  string
+		expectedArgs []string
+	}{
+		{
+			title:   "unknown runtime",
+			runtime: "rkt",
+			err:     "unknown runtime",
+		},
+		{
+			title:   "empty packages podman",
+			runtime: "podman",
+			err:     "no packages given",
+		},
+		{
+			title:     "relative package native",
+			runtime:   "native",
+			outputDir: "/.build-data/bundles",
+			packages:  []string{"package.rpm"},
+			err:       "package is not an absolute path",
+		},
+		{
+			title:     "split packages nerdctl",
+			runtime:   "nerdctl",
+			outputDir: "/.build-data/bundles",
+			packages:  []string{"/a/package-a.rpm", "/b/package-b.rpm"},
+			err:       "packages are not all in the same directory",
+		},
+		{
+			title:       "docker",
+			runtime:     "docker",
+			outputDir:   "/.build-data/bundles",
+			packages:    []string{"/package.rpm"},
+			expectedCmd: "docker",
+			expectedArgs: []string{
+				"run", "--privileged", "--rm", "-t",
+				"--name", "repackage-sha",
+				"-v", "/dev:/dev",
+				"-v", "/:/input:ro",
+				"-v", "/.build-data/bundles:/output",
+				"repackage:latest", "sha", "redhat", "/output", "/input/package.rpm",
+			},
+		},
+		{
+			title:       "podman",
+			runtime:     "podman",
+			outputDir:   "/.build-data/bundles",
+			packages:    []string{"/package.rpm"},
+			expectedCmd: "podman",
+			expectedArgs: []string{
+				"run", "--privileged", "--rm", "-t",
+				"--name", "repackage-sha",
+				"--security-opt", "label=disable",
+				"-v", "/dev:/dev",
+				"-v", "/:/input:ro",
+				"-v", "/.build-data/bundles:/output",
+				"repackage:latest", "sha", "redhat", "/output", "/input/package.rpm",
+			},
+		},
+		{
+			title:       "nerdctl",
+			runtime:     "nerdctl",
+			outputDir:   "/.build-data/bundles",
+			packages:    []string{"/package.rpm"},
+			expectedCmd: "nerdctl",
+			expectedArgs: []string{
+				"run", "--privileged", "--rm",
+				"--name", "repackage-sha",
+				"-v", "/dev:/dev",
+				"-v", "/:/input:ro",
+				"-v", "/.build-data/bundles:/output",
+				"repackage:latest", "sha", "redhat", "/output", "/input/package.rpm",
+			},
+		},
+		{
+			title:     "native",
+			runtime:   "native",
+			outputDir: "/.build-data/bundles",
+			packages: []string{
+				"/packages/package-a.deb",
+				"/packages/package-b.deb",
+			},
+			expectedCmd: "packers/entrypoint",
+			expectedArgs: []string{
+				"sha", "redhat", "/.build-data/bundles",
+				"/packages/package-a.deb",
+				"/packages/package-b.deb",
+			},
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			runtime, err := GetRuntime(test.runtime)
+			if err == nil {
+				var cmd string
+				var args []string
+				cmd, args, err = runtime.Command("", "sha", "redhat", test.outputDir, test.packages)
+				if err == nil {
+					require.Equal(t, test.expectedCmd, cmd)
+					require.Equal(t, test.expectedArgs, args)
+				}
+			}
+
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRunContextTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := RunContext(ctx, ioutil.Discard, ioutil.Discard, "sleep", "5")
+	require.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestRunContextError(t *testing.T) {
+	err := RunContext(context.Background(), ioutil.Discard, ioutil.Discard, "false")
+	require.Error(t, err)
+	require.NotEqual(t, context.DeadlineExceeded, err)
+}
+
+func TestRunGroupContextTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	// The shell prints the pid of its child, which must be killed with it.
+	var out bytes.Buffer
+	err := RunGroupContext(ctx, &out, ioutil.Discard, nil, nil, "sh", "-c", "sleep 30 & echo $!; wait")
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	pid, err := strconv.Atoi(strings.TrimSpace(out.String()))
+	require.NoError(t, err)
+	waitFor(t, func() bool { return !running(pid) })
+}
+
+func TestNativeRuntimeKill(t *testing.T) {
+	var (
+		runtime = NativeRuntime{}
+		out     bytes.Buffer
+		errs    = make(chan error, 1)
+	)
+	require.NoError(t, runtime.Kill("sha"))
+
+	go func() {
+		errs <- runtime.Run(context.Background(), &out, "sha", "sh", []string{"-c", "sleep 30 & echo $!; wait"})
+	}()
+	waitFor(t, func() bool {
+		_, found := nativeGroups.get("sha")
+		return found
+	})
+
+	require.NoError(t, runtime.Kill("sha"))
+	require.Error(t, <-errs)
+
+	pid, err := strconv.Atoi(strings.TrimSpace(out.String()))
+	require.NoError(t, err)
+	waitFor(t, func() bool { return !running(pid) })
+
+	_, found := nativeGroups.get("sha")
+	require.False(t, found)
+}
+
+// waitFor waits for the given condition to hold, and fails the test if it
+// doesn't within a few seconds.
+func waitFor(t *testing.T, condition func() bool) {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if condition() {
+			return
+		}
+	}
+	t.Fatal("condition did not hold in time")
+}
+
+// running reports whether the process with the given pid is running. Zombie
+// processes, that have exited but are yet to be reaped, are not running.
+func running(pid int) bool {
+	if err := syscall.Kill(pid, 0); err != nil {
+		return false
+	}
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return true
+	}
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
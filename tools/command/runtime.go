@@ -0,0 +1,233 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultImage is the packer image used for builders that don't name one.
+const DefaultImage = "repackage:latest"
+
+// Runtime constructs the command lines used to run, and to abandon, a packer
+// build.
+type Runtime interface {
+	// Command returns the command line that repackages the given packages
+	// into a bundle inside of outputDir.
+	Command(image string, checksum string, distroName string, outputDir string, packages []string) (string, []string, error)
+
+	// Run runs the given command line, as returned by Command for the build
+	// with the given checksum, and streams its output to the given writer.
+	// The command is killed if the context is done before it completes, in
+	// which case the context error is returned.
+	Run(ctx context.Context, out io.Writer, checksum string, name string, args []string) error
+
+	// Kill stops the build with the given checksum, if it is still running.
+	Kill(checksum string) error
+}
+
+var runtimes = map[string]Runtime{
+	"docker": containerRuntime{
+		binary: "docker",
+		tty:    true,
+	},
+	"podman": containerRuntime{
+		binary: "podman",
+		tty:    true,
+		// Volumes can't be relabeled, since /dev is one of them. Disable
+		// SELinux separation instead, so that they are accessible.
+		extraArgs: []string{"--security-opt", "label=disable"},
+	},
+	"nerdctl": containerRuntime{
+		// nerdctl does not allow -t without -i, and no terminal is needed.
+		binary: "nerdctl",
+	},
+	"native": NativeRuntime{
+		Entrypoint: "packers/entrypoint",
+	},
+}
+
+// GetRuntime returns the given runtime by name, or an error if it does not
+// exist.
+func GetRuntime(name string) (Runtime, error) {
+	runtime, found := runtimes[name]
+	if !found {
+		return nil, errors.New("unknown runtime")
+	}
+	return runtime, nil
+}
+
+// containerRuntime runs the packer image with a docker compatible CLI.
+type containerRuntime struct {
+	// binary is the name of the container CLI.
+	binary string
+
+	// tty controls whether a pseudo-terminal is allocated for the container.
+	tty bool
+
+	// extraArgs are added to the run command, before any volume mappings.
+	extraArgs []string
+}
+
+func (r containerRuntime) Command(image string, checksum string, distroName string, outputDir string, packages []string) (string, []string, error) {
+	var args = []string{
+		"run",
+		"--privileged",
+		"--rm",
+	}
+
+	if r.tty {
+		args = append(args, "-t")
+	}
+
+	if len(image) == 0 {
+		image = DefaultImage
+	}
+
+	pkgDir, err := checkPaths(outputDir, packages)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Name the container, so that it can be killed if the build is abandoned.
+	args = append(args, "--name", ContainerName(checksum))
+
+	args = append(args, r.extraArgs...)
+
+	// Add host mount for loopback device mounting
+	args = append(args, "-v", "/dev:/dev")
+
+	// Add a read-only volume mapping for directory containing the given packages.
+	args = append(args, "-v", fmt.Sprintf("%s:/input:ro", pkgDir))
+
+	// Add a single volume mapping for the output directory.
+	args = append(args, "-v", fmt.Sprintf("%s:/output", outputDir))
+
+	// Add the Docker image name, distro name, and output directory alias
+	args = append(args, image, checksum, distroName, "/output")
+
+	// Add a series of package names, same as the volume aliases.
+	for _, pkg := range packages {
+		args = append(args, fmt.Sprintf("/input/%s", filepath.Base(pkg)))
+	}
+
+	return r.binary, args, nil
+}
+
+func (r containerRuntime) Run(ctx context.Context, out io.Writer, checksum string, name string, args []string) error {
+	return RunContext(ctx, out, out, name, args...)
+}
+
+// Kill kills the running container for the given checksum. Killing the
+// container CLI alone leaves the container running, so this must be called
+// when a build is abandoned. It is not an error if no such container exists.
+func (r containerRuntime) Kill(checksum string) error {
+	name := ContainerName(checksum)
+	out, err := exec.Command(r.binary, "kill", name).CombinedOutput()
+	if err != nil && !strings.Contains(strings.ToLower(string(out)), "no such container") {
+		return fmt.Errorf("failed to kill container %s: %s", name, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// NativeRuntime runs the packer entrypoint script directly on the host,
+// without a container. The host must provide every tool that the packer image
+// does, including bundle-meta, and the script must be run as root.
+type NativeRuntime struct {
+	// Entrypoint is the path to the packers/entrypoint script.
+	Entrypoint string
+}
+
+func (r NativeRuntime) Command(image string, checksum string, distroName string, outputDir string, packages []string) (string, []string, error) {
+	if _, err := checkPaths(outputDir, packages); err != nil {
+		return "", nil, err
+	}
+
+	// The image is ignored, as the packages are passed to the script as-is.
+	args := []string{checksum, distroName, outputDir}
+	args = append(args, packages...)
+
+	return r.Entrypoint, args, nil
+}
+
+// Run runs the entrypoint script in a process group of its own, so that the
+// tools that it runs, such as kpartx, mount and make, are killed along with it.
+func (r NativeRuntime) Run(ctx context.Context, out io.Writer, checksum string, name string, args []string) error {
+	return RunGroupContext(ctx, out, out,
+		func(pgid int) { nativeGroups.set(checksum, pgid) },
+		func() { nativeGroups.remove(checksum) },
+		name, args...)
+}
+
+// Kill kills the process group of the running entrypoint script for the given
+// checksum. Killing the script alone leaves the tools that it runs holding on
+// to loop devices and mounts. It is not an error if no such build is running.
+func (r NativeRuntime) Kill(checksum string) error {
+	pgid, found := nativeGroups.get(checksum)
+	if !found {
+		return nil
+	}
+	if err := KillGroup(pgid); err != nil {
+		return fmt.Errorf("failed to kill process group %d: %v", pgid, err)
+	}
+	return nil
+}
+
+// nativeGroups holds the process group ids of the running native builds.
+var nativeGroups = processGroups{pgids: make(map[string]int)}
+
+// processGroups maps build checksums to process group ids, and is safe for
+// concurrent use.
+type processGroups struct {
+	mu    sync.Mutex
+	pgids map[string]int
+}
+
+func (g *processGroups) set(checksum string, pgid int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.pgids[checksum] = pgid
+}
+
+func (g *processGroups) get(checksum string) (int, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	pgid, found := g.pgids[checksum]
+	return pgid, found
+}
+
+func (g *processGroups) remove(checksum string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.pgids, checksum)
+}
+
+// checkPaths verifies that the given output directory and packages are all
+// absolute paths, and that the packages share a single directory, which is
+// returned.
+func checkPaths(outputDir string, packages []string) (string, error) {
+	if len(packages) == 0 {
+		return "", errors.New("no packages given")
+	}
+
+	if !filepath.IsAbs(outputDir) {
+		return "", errors.New("output directory is not an absolute path")
+	}
+
+	pkgDir := filepath.Dir(packages[0])
+	for _, pkg := range packages {
+		if !filepath.IsAbs(pkg) {
+			return "", errors.New("package is not an absolute path")
+		}
+		if pkgDir != filepath.Dir(pkg) {
+			return "", errors.New("packages are not all in the same directory")
+		}
+	}
+
+	return pkgDir, nil
+}
@@ -0,0 +1,178 @@
+package images
+
+import (
+	"io/ioutil"
+	"regexp"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/stackrox/kernel-packer/tools/pkginfo"
+)
+
+// kernelVersionRegex matches the kernel versions that rules may be limited to.
+var kernelVersionRegex = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+
+type (
+	// Config selects the packer image that every package set of the manifest
+	// is built with. Rules of the reformat entry of a package set are tried
+	// first, then rules of its kind, and then the default image.
+	Config struct {
+		// Default is the image for package sets that no rule matches. An
+		// empty image is the default packer image.
+		Default string `yaml:"default,omitempty"`
+
+		// Kinds are the rules for each kind, such as "ubuntu".
+		Kinds map[string]Rules `yaml:"kinds,omitempty"`
+
+		// Entries are the rules for each reformat entry, by name.
+		Entries map[string]Rules `yaml:"entries,omitempty"`
+	}
+
+	// Rules select the image for package sets of a kind or reformat entry.
+	Rules struct {
+		// Rules are tried in order, and the first match selects the image.
+		Rules []Rule `yaml:"rules,omitempty"`
+
+		// Default is the image for package sets that no rule matches. It
+		// falls through to the next level if empty.
+		Default string `yaml:"default,omitempty"`
+	}
+
+	// Rule selects an image for package sets that match all of its
+	// conditions.
+	Rule struct {
+		// MinKernelVersion and MaxKernelVersion match the kernel versions in
+		// between, inclusive.
+		MinKernelVersion string `yaml:"minKernelVersion,omitempty"`
+		MaxKernelVersion string `yaml:"maxKernelVersion,omitempty"`
+
+		// Package is a regular expression, that matches package sets that
+		// have a package with a matching name.
+		Package string `yaml:"package,omitempty"`
+
+		// Image is the packer image that is selected.
+		Image string `yaml:"image"`
+
+		packageRegex *regexp.Regexp
+	}
+)
+
+// Load reads the given filename as yaml, and validates its rules.
+func Load(filename string) (*Config, error) {
+	body, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read image config file")
+	}
+
+	var cfg Config
+	if err := yaml.UnmarshalStrict(body, &cfg); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal image config file")
+	}
+
+	if err := cfg.Compile(); err != nil {
+		return nil, errors.Wrapf(err, "invalid image config file %s", filename)
+	}
+
+	return &cfg, nil
+}
+
+// Compile checks every rule of the config, and compiles their regular
+// expressions.
+func (c *Config) Compile() error {
+	for _, level := range []struct {
+		name  string
+		rules map[string]Rules
+	}{
+		{name: "kind", rules: c.Kinds},
+		{name: "entry", rules: c.Entries},
+	} {
+		for key, rules := range level.rules {
+			for index := range rules.Rules {
+				if err := rules.Rules[index].compile(); err != nil {
+					return errors.Wrapf(err, "%s %s rule %d", level.name, key, index+1)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Rule) compile() error {
+	if r.Image == "" {
+		return errors.New("image is missing")
+	}
+
+	for _, version := range []string{r.MinKernelVersion, r.MaxKernelVersion} {
+		if version != "" && !kernelVersionRegex.MatchString(version) {
+			return errors.Errorf("invalid kernel version %q", version)
+		}
+	}
+	if r.MinKernelVersion != "" && r.MaxKernelVersion != "" &&
+		pkginfo.VersionLess(r.MaxKernelVersion, r.MinKernelVersion) {
+		return errors.Errorf("minKernelVersion %q is greater than maxKernelVersion %q", r.MinKernelVersion, r.MaxKernelVersion)
+	}
+
+	if r.Package != "" {
+		regex, err := regexp.Compile(r.Package)
+		if err != nil {
+			return errors.Wrapf(err, "invalid package pattern %q", r.Package)
+		}
+		r.packageRegex = regex
+	}
+	return nil
+}
+
+// matches reports whether the given package set, of the given kernel version,
+// matches every condition of the rule. Rules with a kernel version range never
+// match package sets without a kernel version.
+func (r *Rule) matches(kernelVersion string, packages []string) bool {
+	if r.MinKernelVersion != "" || r.MaxKernelVersion != "" {
+		if kernelVersion == "" {
+			return false
+		}
+		if r.MinKernelVersion != "" && pkginfo.VersionLess(kernelVersion, r.MinKernelVersion) {
+			return false
+		}
+		if r.MaxKernelVersion != "" && pkginfo.VersionLess(r.MaxKernelVersion, kernelVersion) {
+			return false
+		}
+	}
+
+	if r.packageRegex != nil {
+		for _, pkg := range packages {
+			if r.packageRegex.MatchString(pkg) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// selectImage returns the image of the first matching rule, or else the
+// default image, if any.
+func (r Rules) selectImage(kernelVersion string, packages []string) (string, bool) {
+	for index := range r.Rules {
+		if r.Rules[index].matches(kernelVersion, packages) {
+			return r.Rules[index].Image, true
+		}
+	}
+	return r.Default, r.Default != ""
+}
+
+// Image returns the packer image for the given package set, of the given
+// reformat entry, kind and kernel version. A nil config selects the default
+// packer image for everything.
+func (c *Config) Image(entry string, kind string, kernelVersion string, packages []string) string {
+	if c == nil {
+		return ""
+	}
+	if image, found := c.Entries[entry].selectImage(kernelVersion, packages); found {
+		return image
+	}
+	if image, found := c.Kinds[kind].selectImage(kernelVersion, packages); found {
+		return image
+	}
+	return c.Default
+}
@@ -0,0 +1,185 @@
+package images
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		title  string
+		config Config
+		err    string
+	}{
+		{
+			title: "valid",
+			config: Config{
+				Kinds: map[string]Rules{
+					"ubuntu": {Rules: []Rule{{MinKernelVersion: "6.2.0", Image: "repackage-bookworm"}}},
+				},
+				Entries: map[string]Rules{
+					"rhel9": {Rules: []Rule{{Package: `\.el9`, Image: "repackage-bookworm"}}},
+				},
+			},
+		},
+		{
+			title: "image is missing",
+			config: Config{
+				Kinds: map[string]Rules{
+					"ubuntu": {Rules: []Rule{{MinKernelVersion: "6.2.0"}}},
+				},
+			},
+			err: "kind ubuntu rule 1: image is missing",
+		},
+		{
+			title: "invalid kernel version",
+			config: Config{
+				Kinds: map[string]Rules{
+					"debian": {Rules: []Rule{{MaxKernelVersion: "6.x", Image: "repackage-bookworm"}}},
+				},
+			},
+			err: `kind debian rule 1: invalid kernel version "6.x"`,
+		},
+		{
+			title: "min greater than max",
+			config: Config{
+				Entries: map[string]Rules{
+					"debian": {Rules: []Rule{
+						{Image: "repackage-bookworm"},
+						{MinKernelVersion: "6.1.0", MaxKernelVersion: "5.10.0", Image: "repackage-bookworm"},
+					}},
+				},
+			},
+			err: `entry debian rule 2: minKernelVersion "6.1.0" is greater than maxKernelVersion "5.10.0"`,
+		},
+		{
+			title: "invalid package pattern",
+			config: Config{
+				Entries: map[string]Rules{
+					"rhel9": {Rules: []Rule{{Package: `[`, Image: "repackage-bookworm"}}},
+				},
+			},
+			err: "entry rhel9 rule 1: invalid package pattern \"[\": error parsing regexp: missing closing ]: `[`",
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			err := test.config.Compile()
+			if test.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.err)
+			}
+		})
+	}
+}
+
+func TestImage(t *testing.T) {
+	config := Config{
+		Default: "repackage-default",
+		Kinds: map[string]Rules{
+			"ubuntu": {
+				Rules: []Rule{
+					{MinKernelVersion: "6.2.0", Image: "repackage-bookworm"},
+				},
+			},
+			"debian": {
+				Rules: []Rule{
+					{MinKernelVersion: "6.0.0", MaxKernelVersion: "6.0.99", Image: "repackage-bullseye"},
+					{MinKernelVersion: "6.0.0", Image: "repackage-bookworm"},
+				},
+				Default: "repackage-buster",
+			},
+		},
+		Entries: map[string]Rules{
+			"ubuntu-aws": {
+				Rules: []Rule{
+					{Package: `-aws_`, Image: "repackage-aws"},
+				},
+			},
+		},
+	}
+	require.NoError(t, config.Compile())
+
+	tests := []struct {
+		title         string
+		entry         string
+		kind          string
+		kernelVersion string
+		packages      []string
+		expected      string
+	}{
+		{
+			title:         "kind rule",
+			entry:         "ubuntu-gcp",
+			kind:          "ubuntu",
+			kernelVersion: "6.2.0",
+			expected:      "repackage-bookworm",
+		},
+		{
+			title:         "no kind rule matches",
+			entry:         "ubuntu-gcp",
+			kind:          "ubuntu",
+			kernelVersion: "5.15.0",
+			expected:      "repackage-default",
+		},
+		{
+			title:         "first kind rule matches",
+			entry:         "debian",
+			kind:          "debian",
+			kernelVersion: "6.0.12",
+			expected:      "repackage-bullseye",
+		},
+		{
+			title:         "second kind rule matches",
+			entry:         "debian",
+			kind:          "debian",
+			kernelVersion: "6.1.0",
+			expected:      "repackage-bookworm",
+		},
+		{
+			title:         "kind default",
+			entry:         "debian",
+			kind:          "debian",
+			kernelVersion: "5.10.0",
+			expected:      "repackage-buster",
+		},
+		{
+			title:    "no kernel version",
+			entry:    "debian",
+			kind:     "debian",
+			expected: "repackage-buster",
+		},
+		{
+			title:         "entry rule before kind rule",
+			entry:         "ubuntu-aws",
+			kind:          "ubuntu",
+			kernelVersion: "6.2.0",
+			packages:      []string{"linux-headers-6.2.0-1005-aws_6.2.0-1005.5_amd64.deb"},
+			expected:      "repackage-aws",
+		},
+		{
+			title:         "entry rule does not match",
+			entry:         "ubuntu-aws",
+			kind:          "ubuntu",
+			kernelVersion: "6.2.0",
+			packages:      []string{"linux-headers-6.2.0-1005-gcp_6.2.0-1005.5_amd64.deb"},
+			expected:      "repackage-bookworm",
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, config.Image(test.entry, test.kind, test.kernelVersion, test.packages))
+		})
+	}
+
+	var nilConfig *Config
+	assert.Equal(t, "", nilConfig.Image("ubuntu-gcp", "ubuntu", "6.2.0", nil))
+}
@@ -5,12 +5,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
-	"regexp"
 	"sort"
 
 	"github.com/fatih/color"
 	"github.com/pkg/errors"
-	"github.com/stackrox/kernel-packer/tools/generate-manifest/reformatters"
+	"github.com/stackrox/kernel-packer/tools/config/images"
+	"github.com/stackrox/kernel-packer/tools/pkginfo"
+	"github.com/stackrox/kernel-packer/tools/util"
 	"gopkg.in/yaml.v2"
 )
 
@@ -25,66 +26,107 @@ type Builder struct {
 	Bundle    string   `yaml:"bundle,omitempty"`
 	NodeIndex int      `yaml:"nodeIndex,omitempty"`
 	Image     string   `yaml:"image,omitempty"`
+	Attempts  int      `yaml:"attempts,omitempty"`
+
+	// NodeSource names where the node index of the build was taken from, such
+	// as "flags" or "github-actions", as recorded in the build cache.
+	NodeSource string `yaml:"nodeSource,omitempty"`
+
+	// Arch is the kernel architecture of the packages, such as "aarch64". It
+	// is empty for x86_64, the default architecture, so that the ids of
+	// existing builders are unchanged.
+	Arch string `yaml:"arch,omitempty"`
+
+	// Kernel is the kernel release, as in "uname -r", that the packages are
+	// of, such as "5.4.0-1048-gke". It is empty if the packages don't name
+	// it, such as COS and Flatcar packages.
+	Kernel string `yaml:"kernel,omitempty"`
+
+	// Flavour is the kernel flavour that the packages are of, such as "gke",
+	// "cloud-amd64" or "rt". It is empty for the default flavour of
+	// distributions that don't name it.
+	Flavour string `yaml:"flavour,omitempty"`
+
+	// DurationSeconds is how long the build took, as recorded in the build
+	// cache. It is used to balance future builds across build nodes.
+	DurationSeconds int `yaml:"durationSeconds,omitempty"`
+
+	// Fingerprint is a checksum of the packer sources that the build was
+	// made with, as recorded in the build cache. Builds are redone when it no
+	// longer matches the current packer sources.
+	Fingerprint string `yaml:"fingerprint,omitempty"`
 }
 
-type ImageThreshold struct {
-	KernelVersion string
-	ImageName     string
-}
-
-var (
-	numericVersionRegex = regexp.MustCompile(`(\d\.\d+\.\d+)`)
-
-	kindImageMapping = map[string][]ImageThreshold{
-		"ubuntu": []ImageThreshold{
-			ImageThreshold{
-				KernelVersion: "6.2.0",
-				ImageName:     "repackage-bookworm",
-			},
-		},
-	}
-)
-
-// Adds a Builder with the given kind and packages to the Manifest under an
-// id derived by checksumming the given set of packages.
-func (m Manifest) Add(kind string, packages []string) {
-	var id = checksumStrings(packages)
-
-	// An empty image tag will be omitted
-	image := ""
-	parts := []string{}
+// Adds a Builder with the given kind, architecture and packages, of the given
+// reformat entry, to the Manifest under an id derived by checksumming the
+// given set of packages and architecture. The architecture is taken from the
+// packages if empty. The image of the Builder is selected by the given image
+// config.
+func (m Manifest) Add(imageConfig *images.Config, entry string, kind string, arch string, packages []string) {
+	var kernelVersion string
 
 	// Take the version from the first package, under the assumption that all
 	// the items should have the same kernel version
 	if len(packages) > 0 {
-		parts = numericVersionRegex.FindStringSubmatch(packages[0])
-	}
-
-	// Verify corresponding image mappings
-	if len(parts) > 1 {
-		mapping, exists := kindImageMapping[kind]
-
-		if exists {
-			for _, versionToImage := range mapping {
-				if !reformatters.VersionLess(parts[1], versionToImage.KernelVersion) {
-					image = versionToImage.ImageName
-				}
-			}
+		if info, err := pkginfo.Parse(packages[0]); err == nil {
+			kernelVersion = info.KernelVersion
 		}
 	}
 
-	m[id] = Builder{
+	builder := NewBuilder(kind, arch, packages)
+	// An empty image tag will be omitted
+	builder.Image = imageConfig.Image(entry, kind, kernelVersion, packages)
+	m.AddBuilder(builder)
+}
+
+// NewBuilder returns a Builder of the given kind, architecture and packages,
+// with the kernel release and flavour that the packages name. The
+// architecture is taken from the packages if empty.
+func NewBuilder(kind string, arch string, packages []string) Builder {
+	builder := Builder{
 		Kind:     kind,
-		Image:    image,
 		Packages: packages,
+		Arch:     builderArch(arch, packages),
+	}
+
+	// The kernel release is named by the flavour specific package of the set,
+	// such as the headers of Ubuntu and Debian kernels.
+	for _, pkg := range packages {
+		info, err := pkginfo.Parse(pkg)
+		if err != nil {
+			continue
+		}
+		if uname := info.Uname(); uname != "" {
+			builder.Kernel = uname
+			builder.Flavour = info.Flavour
+			break
+		}
+		if builder.Flavour == "" {
+			builder.Flavour = info.Flavour
+		}
 	}
+	return builder
 }
 
 // Adds a Builder to the Manifest under an id derived by checksumming the
-// set of packages in the Builder.
+// set of packages and the architecture in the Builder.
 func (m Manifest) AddBuilder(builder Builder) {
-	id := checksumStrings(builder.Packages)
-	m[id] = builder
+	m[builderID(builder.Arch, builder.Packages)] = builder
+}
+
+// builderArch returns the architecture of a Builder of the given packages, as
+// given, or else as named by the packages. It is empty for the default
+// architecture.
+func builderArch(arch string, packages []string) string {
+	if arch == "" {
+		arch = util.PackagesArch(packages)
+	} else if normalized, found := util.NormalizeArch(arch); found {
+		arch = normalized
+	}
+	if arch == util.DefaultArch {
+		return ""
+	}
+	return arch
 }
 
 // SortedIDs returns a list of all manifest ids, sorted in alphabetical order.
@@ -174,6 +216,16 @@ func CombineDir(directory string) (Manifest, error) {
 	return CombineFiles(matches)
 }
 
+// builderID returns the id of a Builder of the given architecture and
+// packages. Builders of the default architecture are identified by their
+// packages alone.
+func builderID(arch string, packages []string) string {
+	if arch == "" {
+		return checksumStrings(packages)
+	}
+	return checksumStrings(append([]string{"arch:" + arch}, packages...))
+}
+
 // checksumStrings returns a consistent hash for the given set of package names.
 func checksumStrings(packages []string) string {
 	var (
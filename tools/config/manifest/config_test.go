@@ -0,0 +1,87 @@
+package manifest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddArch(t *testing.T) {
+	var (
+		amd64Packages = []string{
+			"http---security.ubuntu.com-ubuntu-pool-main-l-linux-gcp-5.8-linux-gcp-5.8-headers-5.8.0-1036_5.8.0-1036.38-20.04.1_amd64.deb",
+			"http---security.ubuntu.com-ubuntu-pool-main-l-linux-gcp-5.8-linux-headers-5.8.0-1036-gcp_5.8.0-1036.38-20.04.1_amd64.deb",
+		}
+		cosPackages = []string{"https---storage.googleapis.com-cos-tools-12739.68.0-kernel-src.tar.gz"}
+		mf          = New()
+	)
+
+	// Builders of the default architecture keep the ids of manifests from
+	// before architectures were recorded.
+	mf.Add(nil, "ubuntu-gcp", "ubuntu", "", amd64Packages)
+	assert.Equal(t, Builder{Kind: "ubuntu", Packages: amd64Packages, Kernel: "5.8.0-1036-gcp", Flavour: "gcp"}, mf["0f0e0b18286bb2a43660c9ac0a375a14d1532c926c4ddca58cdf42a17f4f3def"])
+
+	// The same packages of another architecture get another id.
+	mf.Add(nil, "cos", "cos", "x86_64", cosPackages)
+	mf.Add(nil, "cos-arm64", "cos", "arm64", cosPackages)
+	assert.Len(t, mf, 3)
+	assert.Contains(t, mf, checksumStrings(cosPackages))
+	assert.Equal(t, "aarch64", mf[builderID("aarch64", cosPackages)].Arch)
+}
+
+func TestNewBuilder(t *testing.T) {
+	tests := []struct {
+		title    string
+		kind     string
+		packages []string
+		kernel   string
+		flavour  string
+	}{
+		{
+			title: "debian",
+			kind:  "debian",
+			packages: []string{
+				"http---security.debian.org-pool-updates-main-l-linux-linux-kbuild-6.1_6.1.90-1_amd64.deb",
+				"http---security.debian.org-pool-updates-main-l-linux-linux-headers-6.1.0-21-cloud-amd64_6.1.90-1_amd64.deb",
+				"http---security.debian.org-pool-updates-main-l-linux-linux-headers-6.1.0-21-common_6.1.90-1_all.deb",
+			},
+			kernel:  "6.1.0-21-cloud-amd64",
+			flavour: "cloud-amd64",
+		},
+		{
+			title:    "redhat",
+			kind:     "redhat",
+			packages: []string{"https---mirrors.kernel.org-centos-7.9.2009-updates-x86_64-Packages-kernel-devel-3.10.0-1160.80.1.el7.x86_64.rpm"},
+			kernel:   "3.10.0-1160.80.1.el7.x86_64",
+		},
+		{
+			title: "suse",
+			kind:  "suse",
+			packages: []string{
+				"https---updates.suse.com-SUSE-Updates-SLE-Module-Basesystem-15-SP3-x86_64-update-noarch-kernel-devel-5.3.18-150300.59.63.1.noarch.rpm",
+				"https---updates.suse.com-SUSE-Updates-SLE-Module-Basesystem-15-SP3-x86_64-update-x86_64-kernel-default-devel-5.3.18-150300.59.63.1.x86_64.rpm",
+			},
+			kernel:  "5.3.18-150300.59.63-default",
+			flavour: "default",
+		},
+		{
+			title: "cos",
+			kind:  "cos",
+			packages: []string{
+				"https---storage.googleapis.com-cos-tools-12739.68.0-kernel-src.tar.gz",
+				"https---storage.googleapis.com-cos-tools-12739.68.0-kernel-headers.tgz",
+			},
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			builder := NewBuilder(test.kind, "", test.packages)
+			assert.Equal(t, test.kind, builder.Kind)
+			assert.Equal(t, test.kernel, builder.Kernel)
+			assert.Equal(t, test.flavour, builder.Flavour)
+		})
+	}
+}
@@ -1,22 +1,59 @@
 package reformat
 
 import (
+	"fmt"
 	"io/ioutil"
+	"regexp"
 
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
+
+	"github.com/stackrox/kernel-packer/tools/pkginfo"
+	"github.com/stackrox/kernel-packer/tools/util"
 )
 
+// kernelVersionRegex matches a kernel version, such as "5.4.0", of the options
+// of an entry.
+var kernelVersionRegex = regexp.MustCompile(`\d+\.\d+\.\d+`)
+
 type (
 	Config []Entry
 
 	Entry struct {
-		Name        string `yml:"name"`
-		Description string `yml:"description"`
-		Type        string `yml:"type"`
-		Reformat    string `yml:"reformat"`
-		Version     string `yml:"version"`
-		File        string `yml:"file"`
+		Name        string `yaml:"name"`
+		Description string `yaml:"description"`
+		Type        string `yaml:"type"`
+		Reformat    string `yaml:"reformat"`
+		File        string `yaml:"file"`
+
+		// Arch is the kernel architecture, such as "x86_64" or "aarch64",
+		// of the packages to use. Packages of other architectures are left
+		// out. Packages that are architecture independent, or that do not
+		// name an architecture, are always used.
+		Arch string `yaml:"arch,omitempty"`
+
+		// MinKernelVersion and MaxKernelVersion limit the package sets that
+		// are used to the kernel versions in between, inclusive.
+		MinKernelVersion string `yaml:"minKernelVersion,omitempty"`
+		MaxKernelVersion string `yaml:"maxKernelVersion,omitempty"`
+
+		// Include are regular expressions, of which a package URL must match
+		// at least one to be used, if given.
+		Include []string `yaml:"include,omitempty"`
+
+		// Exclude are regular expressions, of which a package URL must match
+		// none to be used.
+		Exclude []string `yaml:"exclude,omitempty"`
+
+		// Image is the packer image to build every package set with,
+		// instead of the image selected for its kind and kernel version.
+		Image string `yaml:"image,omitempty"`
+
+		// Disabled entries are left out of the manifest.
+		Disabled bool `yaml:"disabled,omitempty"`
+
+		include []*regexp.Regexp
+		exclude []*regexp.Regexp
 	}
 )
 
@@ -31,5 +68,109 @@ func Load(filename string) (*Config, error) {
 		return nil, errors.Wrap(err, "failed to unmarshal cache file")
 	}
 
+	for index := range cfg {
+		if err := cfg[index].Compile(); err != nil {
+			return nil, errors.Wrapf(err, "invalid entry %q", cfg[index].Name)
+		}
+	}
+
 	return &cfg, nil
 }
+
+// Compile checks the options of the entry, and compiles its regular
+// expressions.
+func (e *Entry) Compile() error {
+	if e.Arch != "" {
+		if _, found := util.NormalizeArch(e.Arch); !found {
+			return errors.Errorf("unknown arch %q", e.Arch)
+		}
+	}
+
+	for _, version := range []string{e.MinKernelVersion, e.MaxKernelVersion} {
+		if version != "" && !kernelVersionRegex.MatchString(version) {
+			return errors.Errorf("invalid kernel version %q", version)
+		}
+	}
+	if e.MinKernelVersion != "" && e.MaxKernelVersion != "" &&
+		pkginfo.VersionLess(e.MaxKernelVersion, e.MinKernelVersion) {
+		return errors.Errorf("minKernelVersion %q is greater than maxKernelVersion %q", e.MinKernelVersion, e.MaxKernelVersion)
+	}
+
+	var err error
+	if e.include, err = compileAll(e.Include); err != nil {
+		return err
+	}
+	if e.exclude, err = compileAll(e.Exclude); err != nil {
+		return err
+	}
+	return nil
+}
+
+func compileAll(patterns []string) ([]*regexp.Regexp, error) {
+	var regexes = make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pattern %q", pattern)
+		}
+		regexes = append(regexes, regex)
+	}
+	return regexes, nil
+}
+
+// FilterURL reports whether the given package URL is used by the entry, by
+// its include and exclude patterns and its architecture. The reason that a
+// URL is not used is also returned.
+func (e *Entry) FilterURL(url string) (bool, string) {
+	for index, regex := range e.exclude {
+		if regex.MatchString(url) {
+			return false, fmt.Sprintf("matches exclude pattern %q", e.Exclude[index])
+		}
+	}
+
+	if len(e.Include) > 0 {
+		var included bool
+		for _, regex := range e.include {
+			if regex.MatchString(url) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false, "matches no include pattern"
+		}
+	}
+
+	if e.Arch != "" {
+		arch, _ := util.NormalizeArch(e.Arch)
+		if pkgArch := util.PackageArch(url); pkgArch != "" && pkgArch != arch {
+			return false, fmt.Sprintf("architecture %s is not %s", pkgArch, e.Arch)
+		}
+	}
+
+	return true, ""
+}
+
+// FilterPackages reports whether the given package set is used by the entry,
+// by the kernel version of its first package that names one, as parsed by
+// pkginfo. Package sets
+// without a kernel version are always used. The reason that a package set is
+// not used is also returned.
+func (e *Entry) FilterPackages(packages []string) (bool, string) {
+	if e.MinKernelVersion == "" && e.MaxKernelVersion == "" {
+		return true, ""
+	}
+
+	version := pkginfo.PackagesKernelVersion(packages)
+	if version == "" {
+		return true, ""
+	}
+
+	if e.MinKernelVersion != "" && pkginfo.VersionLess(version, e.MinKernelVersion) {
+		return false, fmt.Sprintf("kernel version %s is less than minKernelVersion %s", version, e.MinKernelVersion)
+	}
+	if e.MaxKernelVersion != "" && pkginfo.VersionLess(e.MaxKernelVersion, version) {
+		return false, fmt.Sprintf("kernel version %s is greater than maxKernelVersion %s", version, e.MaxKernelVersion)
+	}
+	return true, ""
+}
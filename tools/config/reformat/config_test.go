@@ -0,0 +1,202 @@
+package reformat
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	rpmURL      = "https://mirrors.kernel.org/centos/8/BaseOS/x86_64/os/Packages/kernel-devel-4.18.0-305.el8.x86_64.rpm"
+	rpmArm64URL = "https://mirrors.kernel.org/centos/8/BaseOS/aarch64/os/Packages/kernel-devel-4.18.0-305.el8.aarch64.rpm"
+	debURL      = "http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-headers-5.4.0-1048-gke_5.4.0-1048.50_amd64.deb"
+	debAllURL   = "http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-gke-headers-5.4.0-1048_5.4.0-1048.50_all.deb"
+	debianURL   = "http://security.debian.org/pool/updates/main/l/linux/linux-headers-6.1.0-21-amd64_6.1.90-1_amd64.deb"
+	cosURL      = "https://storage.googleapis.com/cos-tools/12739.68.0/kernel-headers.tgz"
+)
+
+func TestEntryCompile(t *testing.T) {
+	tests := []struct {
+		title string
+		entry Entry
+		err   string
+	}{
+		{
+			title: "no options",
+		},
+		{
+			title: "all options",
+			entry: Entry{
+				Arch:             "arm64",
+				MinKernelVersion: "4.14.0",
+				MaxKernelVersion: "5.4.0",
+				Include:          []string{`\.el8\.`},
+				Exclude:          []string{`-rt-`},
+			},
+		},
+		{
+			title: "unknown arch",
+			entry: Entry{Arch: "ppc64le"},
+			err:   `unknown arch "ppc64le"`,
+		},
+		{
+			title: "invalid kernel version",
+			entry: Entry{MinKernelVersion: "5.4"},
+			err:   `invalid kernel version "5.4"`,
+		},
+		{
+			title: "min greater than max",
+			entry: Entry{MinKernelVersion: "5.10.0", MaxKernelVersion: "5.4.0"},
+			err:   `minKernelVersion "5.10.0" is greater than maxKernelVersion "5.4.0"`,
+		},
+		{
+			title: "invalid pattern",
+			entry: Entry{Exclude: []string{`(`}},
+			err:   `invalid pattern "(": error parsing regexp: missing closing ): ` + "`(`",
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			err := test.entry.Compile()
+			if test.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.err)
+			}
+		})
+	}
+}
+
+func TestEntryFilterURL(t *testing.T) {
+	tests := []struct {
+		title  string
+		entry  Entry
+		url    string
+		keep   bool
+		reason string
+	}{
+		{
+			title: "no options",
+			url:   rpmURL,
+			keep:  true,
+		},
+		{
+			title:  "excluded",
+			entry:  Entry{Exclude: []string{`-gke`}},
+			url:    debURL,
+			reason: `matches exclude pattern "-gke"`,
+		},
+		{
+			title: "included",
+			entry: Entry{Include: []string{`\.el7\.`, `\.el8\.`}},
+			url:   rpmURL,
+			keep:  true,
+		},
+		{
+			title:  "not included",
+			entry:  Entry{Include: []string{`\.el7\.`}},
+			url:    rpmURL,
+			reason: "matches no include pattern",
+		},
+		{
+			title: "same arch by alias",
+			entry: Entry{Arch: "amd64"},
+			url:   rpmURL,
+			keep:  true,
+		},
+		{
+			title:  "other arch",
+			entry:  Entry{Arch: "x86_64"},
+			url:    rpmArm64URL,
+			reason: "architecture aarch64 is not x86_64",
+		},
+		{
+			title:  "other arch deb",
+			entry:  Entry{Arch: "arm64"},
+			url:    debURL,
+			reason: "architecture x86_64 is not arm64",
+		},
+		{
+			title: "architecture independent",
+			entry: Entry{Arch: "aarch64"},
+			url:   debAllURL,
+			keep:  true,
+		},
+		{
+			title: "no arch",
+			entry: Entry{Arch: "aarch64"},
+			url:   cosURL,
+			keep:  true,
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			entry := test.entry
+			assert.NoError(t, entry.Compile())
+
+			keep, reason := entry.FilterURL(test.url)
+			assert.Equal(t, test.keep, keep)
+			assert.Equal(t, test.reason, reason)
+		})
+	}
+}
+
+func TestEntryFilterPackages(t *testing.T) {
+	tests := []struct {
+		title    string
+		entry    Entry
+		packages []string
+		keep     bool
+		reason   string
+	}{
+		{
+			title:    "no range",
+			packages: []string{rpmURL},
+			keep:     true,
+		},
+		{
+			title:    "in range",
+			entry:    Entry{MinKernelVersion: "4.18.0", MaxKernelVersion: "4.18.0"},
+			packages: []string{rpmURL},
+			keep:     true,
+		},
+		{
+			title:    "less than min",
+			entry:    Entry{MinKernelVersion: "5.4.0"},
+			packages: []string{rpmURL},
+			reason:   "kernel version 4.18.0 is less than minKernelVersion 5.4.0",
+		},
+		{
+			title:    "greater than max",
+			entry:    Entry{MaxKernelVersion: "4.19.0"},
+			packages: []string{debAllURL, debURL},
+			reason:   "kernel version 5.4.0 is greater than maxKernelVersion 4.19.0",
+		},
+		{
+			title:    "debian abi",
+			entry:    Entry{MaxKernelVersion: "6.1.0"},
+			packages: []string{debianURL},
+			reason:   "kernel version 6.1.90 is greater than maxKernelVersion 6.1.0",
+		},
+		{
+			title:    "no kernel version",
+			entry:    Entry{MinKernelVersion: "5.4.0"},
+			packages: []string{cosURL},
+			keep:     true,
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			keep, reason := test.entry.FilterPackages(test.packages)
+			assert.Equal(t, test.keep, keep)
+			assert.Equal(t, test.reason, reason)
+		})
+	}
+}
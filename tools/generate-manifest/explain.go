@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/stackrox/kernel-packer/tools/generate-manifest/reformatters"
+	"github.com/stackrox/kernel-packer/tools/util"
+)
+
+// disposition is what became of an input URL.
+type disposition string
+
+const (
+	// dispositionIncluded is for URLs that are part of a manifest build.
+	dispositionIncluded disposition = "included"
+
+	// dispositionMissing is for URLs whose package sets were all left out of
+	// the manifest, as a package is missing from the bucket inventory.
+	dispositionMissing disposition = "missing"
+
+	// dispositionDropped is for URLs that the reformatter left out of every
+	// package set.
+	dispositionDropped disposition = "dropped"
+)
+
+// urlResult is the final disposition of an input URL, and the reason for it.
+type urlResult struct {
+	disposition disposition
+	reason      string
+}
+
+// explanation records what became of every input URL of a reformat entry.
+type explanation struct {
+	entry      string
+	reformat   string
+	urls       []string
+	results    map[string]urlResult
+	dropped    reformatters.Dropped
+	groups     int
+	incomplete int
+}
+
+func newExplanation(entry string, reformat string, urls []string) *explanation {
+	return &explanation{
+		entry:    entry,
+		reformat: reformat,
+		urls:     urls,
+		results:  make(map[string]urlResult),
+		dropped:  make(reformatters.Dropped),
+	}
+}
+
+// included records that the given package set was added to the manifest.
+func (e *explanation) included(packages []string) {
+	e.groups++
+	for _, pkg := range packages {
+		e.results[pkg] = urlResult{disposition: dispositionIncluded}
+	}
+}
+
+// missing records that the given package set was left out of the manifest, as
+// some of its packages are missing from the given bucket inventory.
+func (e *explanation) missing(packages []string, inventory map[string]struct{}) {
+	e.groups++
+	e.incomplete++
+
+	var missingPackages []string
+	for _, pkg := range util.SimplifyURLs(packages) {
+		if _, found := inventory[pkg]; !found {
+			missingPackages = append(missingPackages, pkg)
+		}
+	}
+
+	for _, pkg := range packages {
+		if e.results[pkg].disposition == dispositionIncluded {
+			continue
+		}
+		e.results[pkg] = urlResult{
+			disposition: dispositionMissing,
+			reason:      fmt.Sprintf("missing from bucket inventory: %s", strings.Join(missingPackages, ", ")),
+		}
+	}
+}
+
+// result returns the final disposition of the given input URL. URLs that are
+// in no package set were dropped by the reformatter.
+func (e *explanation) result(url string) urlResult {
+	if result, found := e.results[url]; found {
+		return result
+	}
+	if drop, found := e.dropped[url]; found {
+		return urlResult{disposition: dispositionDropped, reason: drop.Reason}
+	}
+	return urlResult{
+		disposition: dispositionDropped,
+		reason:      fmt.Sprintf("not in any package set of the %s reformatter", e.reformat),
+	}
+}
+
+// printExplanations prints the disposition of every input URL of the given
+// explanations, followed by a summary table with a row for each entry.
+func printExplanations(w io.Writer, explanations []*explanation) error {
+	var tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "ENTRY\tDISPOSITION\tURL\tREASON")
+	for _, e := range explanations {
+		seen := make(map[string]struct{}, len(e.urls))
+		for _, url := range e.urls {
+			if _, found := seen[url]; found {
+				continue
+			}
+			seen[url] = struct{}{}
+
+			result := e.result(url)
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.entry, result.disposition, url, result.reason)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(tw, "ENTRY\tINPUTS\tGROUPS\tINCOMPLETE\tINCLUDED\tMISSING\tDROPPED")
+	for _, e := range explanations {
+		var (
+			counts = make(map[disposition]int)
+			seen   = make(map[string]struct{}, len(e.urls))
+		)
+		for _, url := range e.urls {
+			if _, found := seen[url]; !found {
+				seen[url] = struct{}{}
+				counts[e.result(url).disposition]++
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\n",
+			e.entry, len(seen), e.groups, e.incomplete,
+			counts[dispositionIncluded], counts[dispositionMissing], counts[dispositionDropped])
+	}
+	return tw.Flush()
+}
@@ -8,12 +8,12 @@ import (
 	"net/url"
 	"os"
 	"path"
-	"regexp"
 
 	"github.com/pkg/errors"
 
 	"gopkg.in/yaml.v2"
 
+	"github.com/stackrox/kernel-packer/tools/config/images"
 	"github.com/stackrox/kernel-packer/tools/config/manifest"
 	"github.com/stackrox/kernel-packer/tools/config/reformat"
 	"github.com/stackrox/kernel-packer/tools/generate-manifest/reformatters"
@@ -30,8 +30,7 @@ func main() {
 func partitionURLs(urls []string) ([][]string, error) {
 	urlsByHost := make(map[string][]string)
 	for _, urlStr := range urls {
-		r := regexp.MustCompile(`.*https\:`)
-		urlStr = r.ReplaceAllString(urlStr, "https:")
+		urlStr = util.NormalizeURL(urlStr)
 		u, err := url.Parse(urlStr)
 		if err != nil {
 			return nil, errors.Wrapf(err, "unparseable URL %q", urlStr)
@@ -60,10 +59,58 @@ func partitionURLs(urls []string) ([][]string, error) {
 	return urlGroups, nil
 }
 
+// reformatEntry splits the given package URLs of the given entry into package
+// sets with the given reformatter. URLs and package sets that the options of
+// the entry leave out are recorded in the given dropped URLs, with the reason.
+func reformatEntry(entry reformat.Entry, reformatter reformatters.ReformatterFunc, urls []string, dropped reformatters.Dropped) ([][]string, error) {
+	filteredURLs := make([]string, 0, len(urls))
+	for _, url := range urls {
+		url = util.NormalizeURL(url)
+		if keep, reason := entry.FilterURL(url); !keep {
+			dropped.Skip(url, "%s", reason)
+			continue
+		}
+		filteredURLs = append(filteredURLs, url)
+	}
+
+	// Partition URLs by host
+	urlGroups, err := partitionURLs(filteredURLs)
+	if err != nil {
+		return nil, err
+	}
+
+	var allPackageSets [][]string
+	for _, urlGroup := range urlGroups {
+		// Split the given list of urls into a list of url groups. A given
+		// group will contain 1-3 urls.
+		packageSets, err := reformatter(urlGroup, dropped)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, packages := range packageSets {
+			if keep, reason := entry.FilterPackages(packages); !keep {
+				for _, pkg := range packages {
+					dropped.Skip(pkg, "%s", reason)
+				}
+				continue
+			}
+			allPackageSets = append(allPackageSets, packages)
+		}
+	}
+	return allPackageSets, nil
+}
+
 func mainCmd() error {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		return validateCmd(os.Args[2:])
+	}
+
 	var (
 		configFlag    = flag.String("config", "reformat.yml", "Config file containing reformat manifest.")
+		imagesFlag    = flag.String("images", "images.yml", "Config file containing packer image selection rules.")
 		inventoryFlag = flag.String("bucket-inventory-file", "", "File containing GCS object inventory.")
+		explainFlag   = flag.Bool("explain", false, "Print what became of every package URL, and why, instead of the manifest.")
 	)
 	flag.Parse()
 
@@ -75,12 +122,20 @@ func mainCmd() error {
 		return err
 	}
 
+	imageConfig, err := images.Load(*imagesFlag)
+	if err != nil {
+		return err
+	}
+
 	bucketInventory, err := readInventory(*inventoryFlag)
 	if err != nil {
 		return err
 	}
 
-	var mf = manifest.New()
+	var (
+		mf           = manifest.New()
+		explanations []*explanation
+	)
 
 	for _, entry := range *cfg {
 		var (
@@ -96,42 +151,70 @@ func mainCmd() error {
 			return err
 		}
 
-		// Partition URLs by host
-		urlGroups, err := partitionURLs(urls)
-		if err != nil {
-			return err
+		var explain *explanation
+		if *explainFlag {
+			normalizedURLs := make([]string, len(urls))
+			for index, url := range urls {
+				normalizedURLs[index] = util.NormalizeURL(url)
+			}
+			explain = newExplanation(entry.Name, entry.Reformat, normalizedURLs)
+			explanations = append(explanations, explain)
 		}
 
-		var allPackageSets [][]string
-		for _, urlGroup := range urlGroups {
-			// Split the given list of urls into a list of url groups. A given
-			// group will contain 1-3 urls.
-			packageSets, err := reformatter(urlGroup)
-			if err != nil {
-				return err
+		if entry.Disabled {
+			if explain != nil {
+				for _, url := range explain.urls {
+					explain.dropped.Skip(url, "entry is disabled")
+				}
 			}
+			continue
+		}
+
+		var dropped reformatters.Dropped
+		if explain != nil {
+			dropped = explain.dropped
+		}
 
-			allPackageSets = append(allPackageSets, packageSets...)
+		allPackageSets, err := reformatEntry(entry, reformatter, urls, dropped)
+		if err != nil {
+			return err
 		}
 
-		for _, packages := range allPackageSets {
+		for _, urlSet := range allPackageSets {
 			// Transform the group of urls into a "simplified" group. This is
 			// the naming convention used for storing objects in the GCS bucket.
-			packages = util.SimplifyURLs(packages)
+			packages := util.SimplifyURLs(urlSet)
 
 			// If any of the given urls do not exist in the bucket
 			// inventory, do not add them to the manifest, as they don't exist,
 			// and therefore cannot be built. Maybe they failed to download or
 			// upload during the crawling phase.
 			if missingFromBucketInventory(bucketInventory, packages) {
+				if explain != nil {
+					explain.missing(urlSet, bucketInventory)
+				}
 				continue
 			}
 
-			// Add a builder for the given kind and package group.
-			mf.Add(entry.Type, packages)
+			// Add a builder for the given kind and package group, with the
+			// image of the entry if it overrides the default one.
+			if entry.Image != "" {
+				builder := manifest.NewBuilder(entry.Type, entry.Arch, packages)
+				builder.Image = entry.Image
+				mf.AddBuilder(builder)
+			} else {
+				mf.Add(imageConfig, entry.Name, entry.Type, entry.Arch, packages)
+			}
+			if explain != nil {
+				explain.included(urlSet)
+			}
 		}
 	}
 
+	if *explainFlag {
+		return printExplanations(os.Stdout, explanations)
+	}
+
 	// Render the manifest as raw YAML.
 	body, err := marshalHeader(mf)
 	if body != "" {
@@ -11,12 +11,12 @@ import (
 	"strings"
 
 	"github.com/pkg/errors"
+
+	"github.com/stackrox/kernel-packer/tools/pkginfo"
+	"github.com/stackrox/kernel-packer/tools/util"
 )
 
 var (
-	reVersion   = regexp.MustCompile(`(\d+\.\d+\.\d+-\d+)\.(\d+)(~[\d.]+)?_`)
-	suseVersion = regexp.MustCompile(`\d+\.\d+\.\d+-[a-z]*(?:\d{6}\.)*\d+\.\d+`)
-
 	reformatters = map[string]ReformatterFunc{
 		"one-to-each":  reformatOneToEach,
 		"one-to-pairs": reformatOneToPairs,
@@ -31,7 +31,40 @@ var (
 	supportedUbuntuBackports = []string{"16.04", "20.04"}
 )
 
-type ReformatterFunc func(packages []string) ([][]string, error)
+// ReformatterFunc groups the given packages into the package sets of
+// individual builds. The reason that any package is left out of every package
+// set may be recorded in the given Dropped.
+type ReformatterFunc func(packages []string, dropped Dropped) ([][]string, error)
+
+// Dropped records the reasons that packages were left out of the package sets
+// returned by a reformatter, by package URL.
+type Dropped map[string]Drop
+
+// Drop is the reason that a package was left out of every package set.
+type Drop struct {
+	Reason string
+
+	// Skipped is set for packages that are left out on purpose, such as older
+	// revisions of a package that is used, rather than for being unusable.
+	Skipped bool
+}
+
+// Drop records the reason that the given package could not be used in a
+// package set. Nothing is recorded in a nil Dropped.
+func (d Dropped) Drop(pkg string, format string, args ...interface{}) {
+	if d != nil {
+		d[pkg] = Drop{Reason: fmt.Sprintf(format, args...)}
+	}
+}
+
+// Skip records the reason that the given package was left out of a package
+// set on purpose, such as for being superseded by another package. Nothing is
+// recorded in a nil Dropped.
+func (d Dropped) Skip(pkg string, format string, args ...interface{}) {
+	if d != nil {
+		d[pkg] = Drop{Reason: fmt.Sprintf(format, args...), Skipped: true}
+	}
+}
 
 // Get returns the given reformatter by name, or an error if it does not exist.
 func Get(name string) (ReformatterFunc, error) {
@@ -48,7 +81,7 @@ func Get(name string) (ReformatterFunc, error) {
 //
 // For example:
 // [a, b, c] → [[a, b], [a, c]]
-func reformatOneToEach(packages []string) ([][]string, error) {
+func reformatOneToEach(packages []string, dropped Dropped) ([][]string, error) {
 	var (
 		sets  = make([][]string, 0, len(packages))
 		first = packages[0]
@@ -68,7 +101,7 @@ func reformatOneToEach(packages []string) ([][]string, error) {
 //
 // For example:
 // [a, b, c, d, e] → [[a, b, c], [a, d, e]]
-func reformatOneToPairs(packages []string) ([][]string, error) {
+func reformatOneToPairs(packages []string, dropped Dropped) ([][]string, error) {
 	if len(packages) < 3 || len(packages)%2 == 0 {
 		panic("bad package count")
 	}
@@ -86,10 +119,8 @@ func reformatOneToPairs(packages []string) ([][]string, error) {
 }
 
 var (
-	debianKBuildVersionRegex = regexp.MustCompile(`^linux-kbuild-(\d+(?:\.\d+)*)_([^_]+)(?:_.*)?\.deb$`)
-	debianHeaderVersionRegex = regexp.MustCompile(`^linux-headers-(\d+(?:\.\d+)*-(?:\d+|garden(?:linux)?))-[^_]+_([^_]+)(?:_.*)?\.deb$`)
-	versionSepRegex          = regexp.MustCompile(`[-.]`)
-	debianSecurityURL        = "security.debian.org"
+	versionSepRegex   = regexp.MustCompile(`[-.]`)
+	debianSecurityURL = "security.debian.org"
 )
 
 type packageInfo struct {
@@ -109,7 +140,55 @@ func equalPackagePool(a, b string) bool {
 	return urlA.Host == urlB.Host
 }
 
-func reformatDebian(packages []string) ([][]string, error) {
+// parseDebianKBuild returns the kernel series and package version of the given
+// Debian kbuild package, such as "6.1" and "6.1.90-1".
+func parseDebianKBuild(pkg string) (packageInfo, bool) {
+	info, err := pkginfo.Parse(pkg)
+	if err != nil || !strings.HasPrefix(info.Name, "linux-kbuild-") {
+		return packageInfo{}, false
+	}
+	return packageInfo{
+		url:            pkg,
+		name:           path.Base(pkg),
+		kernelVersion:  info.KernelRelease,
+		packageVersion: info.Version,
+	}, true
+}
+
+// parseDebianHeaders returns the kernel release and package version of the
+// given Debian headers package, such as "6.1.0-21" and "6.1.90-1". Only
+// headers packages that name an ABI are used.
+func parseDebianHeaders(pkg string) (packageInfo, bool) {
+	info, err := pkginfo.Parse(pkg)
+	if err != nil || !strings.HasPrefix(info.Name, "linux-headers-") || info.ABI == "" {
+		return packageInfo{}, false
+	}
+	return packageInfo{
+		url:            pkg,
+		name:           path.Base(pkg),
+		kernelVersion:  info.KernelRelease,
+		packageVersion: info.Version,
+	}, true
+}
+
+// reformatDebian consumes a list of Debian kbuild and headers packages, and
+// returns a list of package groups. Each package group is comprised of a kbuild
+// package, an architecture specific headers package, and the common headers
+// package of the same kernel version, if any. Packages of every architecture
+// are grouped separately, with the architecture independent packages.
+func reformatDebian(packages []string, dropped Dropped) ([][]string, error) {
+	var packageGroups [][]string
+	for _, archPackages := range util.SplitArch(packages) {
+		groups, err := reformatDebianArch(archPackages, dropped)
+		if err != nil {
+			return nil, err
+		}
+		packageGroups = append(packageGroups, groups...)
+	}
+	return packageGroups, nil
+}
+
+func reformatDebianArch(packages []string, dropped Dropped) ([][]string, error) {
 	if len(packages) < 3 {
 		return nil, errors.New("bad package count")
 	}
@@ -120,19 +199,11 @@ func reformatDebian(packages []string) ([][]string, error) {
 	headersByPackageName := make(map[string]packageInfo)
 
 	for _, pkg := range packages {
-		name := path.Base(pkg)
-		matches := debianKBuildVersionRegex.FindStringSubmatch(name)
-		if len(matches) < 3 {
+		pkgInfo, ok := parseDebianKBuild(pkg)
+		if !ok {
 			continue
 		}
 
-		pkgInfo := packageInfo{
-			url:            pkg,
-			name:           name,
-			kernelVersion:  matches[1],
-			packageVersion: matches[2],
-		}
-
 		if existingPkg := kbuildsByPackageVersion[pkgInfo.packageVersion]; existingPkg.url != "" {
 			return nil, errors.Errorf("file clash for kbuild package for package version %s: %s, %s", pkgInfo.packageVersion, existingPkg.url, pkg)
 		}
@@ -143,25 +214,26 @@ func reformatDebian(packages []string) ([][]string, error) {
 
 	for _, pkgInfos := range kbuildsByKernelVersion {
 		sort.Slice(pkgInfos, func(i, j int) bool {
-			return VersionLess(pkgInfos[j].packageVersion, pkgInfos[i].packageVersion)
+			return pkginfo.DebianLess(pkgInfos[j].packageVersion, pkgInfos[i].packageVersion)
 		})
 	}
 
 	for _, pkg := range packages {
-		name := path.Base(pkg)
-		matches := debianHeaderVersionRegex.FindStringSubmatch(name)
-		if len(matches) < 3 {
+		pkgInfo, ok := parseDebianHeaders(pkg)
+		if !ok {
+			if _, isKBuild := parseDebianKBuild(pkg); !isKBuild {
+				dropped.Drop(pkg, "name does not match a kbuild or headers package")
+			}
 			continue
 		}
-		pkgInfo := packageInfo{
-			url:            pkg,
-			name:           name,
-			kernelVersion:  matches[1],
-			packageVersion: matches[2],
-		}
 		// duplicates package files may exist across package pools, prefer security.debian.org over others
 		if existingPkg := headersByPackageName[pkgInfo.name]; !strings.Contains(existingPkg.url, debianSecurityURL) {
+			if existingPkg.url != "" {
+				dropped.Skip(existingPkg.url, "duplicate of %s", pkg)
+			}
 			headersByPackageName[pkgInfo.name] = pkgInfo
+		} else {
+			dropped.Skip(pkg, "duplicate of %s", existingPkg.url)
 		}
 	}
 
@@ -171,25 +243,32 @@ func reformatDebian(packages []string) ([][]string, error) {
 
 	for _, pkgInfos := range headersByKernelVersion {
 		sort.Slice(pkgInfos, func(i, j int) bool {
-			return VersionLess(pkgInfos[j].packageVersion, pkgInfos[i].packageVersion)
+			return pkginfo.DebianLess(pkgInfos[j].packageVersion, pkgInfos[i].packageVersion)
 		})
 	}
 
 	headers := make(map[string][]packageInfo)
 	for _, pkgInfos := range headersByKernelVersion {
-		for idx := 0; idx < len(pkgInfos) && pkgInfos[idx].packageVersion == pkgInfos[0].packageVersion; idx += 1 {
+		idx := 0
+		for ; idx < len(pkgInfos) && pkgInfos[idx].packageVersion == pkgInfos[0].packageVersion; idx += 1 {
 			if !equalPackagePool(pkgInfos[0].url, pkgInfos[idx].url) {
 				return nil, errors.Errorf("invalid mixture of package pools for package version %s: %s, %s", pkgInfos[0].packageVersion, pkgInfos[0].url, pkgInfos[idx].url)
 			}
 			headers[pkgInfos[0].kernelVersion] = append(headers[pkgInfos[0].kernelVersion], pkgInfos[idx])
 		}
+		for ; idx < len(pkgInfos); idx += 1 {
+			dropped.Skip(pkgInfos[idx].url, "older package version %s of kernel version %s, package version %s is used",
+				pkgInfos[idx].packageVersion, pkgInfos[idx].kernelVersion, pkgInfos[0].packageVersion)
+		}
 	}
 
 	packageGroups := make([][]string, 0, len(headers))
+	usedKBuilds := make(map[string]struct{})
 
 	for version, headerPkgs := range headers {
 		// ignore headers without arch specific packages (e.g., linux-headers-5.6.0-2-common_5.6.14-2_all.deb )
 		if len(headerPkgs) == 1 {
+			dropped.Drop(headerPkgs[0].url, "no architecture specific headers package for kernel version %s", version)
 			continue
 		}
 		if len(headerPkgs) > 3 {
@@ -229,11 +308,14 @@ func reformatDebian(packages []string) ([][]string, error) {
 		if len(kbuildCandidates) == 0 {
 			log.Printf("failed to find kbuild package for kernel version %s: "+
 				"candidates are %+v", version, kbuildsByKernelVersion)
+			for _, headerPkg := range headerPkgs {
+				dropped.Drop(headerPkg.url, "no kbuild package for kernel version %s", version)
+			}
 			continue
 		}
 
 		sort.Slice(kbuildCandidates, func(i, j int) bool {
-			return VersionLess(kbuildCandidates[j].packageVersion, kbuildCandidates[i].packageVersion)
+			return pkginfo.DebianLess(kbuildCandidates[j].packageVersion, kbuildCandidates[i].packageVersion)
 		})
 
 		commonHeaderPkg := ""
@@ -248,6 +330,7 @@ func reformatDebian(packages []string) ([][]string, error) {
 			}
 			archHeaderPkgs = append(archHeaderPkgs, headerPkg.url)
 		}
+		usedKBuilds[kbuildCandidates[0].url] = struct{}{}
 		for _, archPkg := range archHeaderPkgs {
 			allPackages := []string{kbuildCandidates[0].url, archPkg}
 			if commonHeaderPkg != "" {
@@ -257,6 +340,12 @@ func reformatDebian(packages []string) ([][]string, error) {
 		}
 	}
 
+	for _, kbuildPkg := range kbuildsByPackageVersion {
+		if _, used := usedKBuilds[kbuildPkg.url]; !used {
+			dropped.Drop(kbuildPkg.url, "no headers package of kernel version %s uses this kbuild package", kbuildPkg.kernelVersion)
+		}
+	}
+
 	return packageGroups, nil
 }
 
@@ -267,11 +356,12 @@ func reformatDebian(packages []string) ([][]string, error) {
 // For example: (Notice that the ".40" revision was dropped in favor of the ".50".)
 // [4.4.0-1031.40_amd64, 4.4.0-1031.40_all, 4.4.0-1031.50_amd64, 4.4.0-1031.50_all, 4.4.0-1069.79_amd64, 4.4.0-1069.79_all] →
 // [[4.4.0-1031.50_amd64, 4.4.0-1031.50_all], [4.4.0-1069.79_amd64, 4.4.0-1069.79_all]]
-func reformatPairs(packages []string) ([][]string, error) {
+func reformatPairs(packages []string, dropped Dropped) ([][]string, error) {
 	type rev struct {
-		packages []string
-		revision int
-		backport bool
+		packages       []string
+		revision       int
+		packageVersion string
+		backport       bool
 	}
 
 	var (
@@ -280,70 +370,92 @@ func reformatPairs(packages []string) ([][]string, error) {
 	)
 
 	for _, pkg := range packages {
-		matches := reVersion.FindStringSubmatch(pkg)
-		// Matches should have 4 items, the full match, the version,
-		// the revision number, and an optional backport version.
-		// Ex: {"5.4.0-1031.33", "5.4.0-1031", "33", "~18.04.1"}
-		if len(matches) != 4 {
-			return nil, fmt.Errorf("regex failed to match")
+		// The version is the kernel release, such as "5.4.0-1031", followed
+		// by the revision number and an optional backport version, such as
+		// "5.4.0-1031.33~18.04.1".
+		info, err := pkginfo.Parse(pkg)
+		if err != nil || info.ABI == "" {
+			return nil, fmt.Errorf("failed to parse version of %s", pkg)
 		}
 
-		version := matches[1]
-		revision, err := strconv.Atoi(matches[2])
+		version := info.KernelRelease
+		revision, err := strconv.Atoi(info.Revision)
 		if err != nil {
-			panic(err)
+			return nil, errors.Wrapf(err, "invalid revision of %s", pkg)
 		}
 
-		backport := "" != matches[3]
+		backport := "" != info.Backport
+
+		// The package version, without any backport, that revisions of the
+		// same version are ordered by.
+		packageVersion := strings.TrimSuffix(info.Version, info.Backport)
 
 		// Add the backport string for Ubuntu to the version if it is supported
 		if backport {
 			for _, supported := range supportedUbuntuBackports {
-				if strings.Contains(matches[3], supported) {
-					version = version + matches[3]
+				if strings.Contains(info.Backport, supported) {
+					version = version + info.Backport
 					break
 				}
 			}
 		}
 		r, found := versions[version]
 
+		var cmp int
+		if found {
+			cmp = pkginfo.DebianCompare(r.packageVersion, packageVersion)
+		}
+
 		switch {
-		case found && r.revision > revision:
-			break
-		case found && r.revision == revision:
+		case found && cmp > 0:
+			dropped.Skip(pkg, "older revision %d of version %s, revision %d is used", revision, version, r.revision)
+		case found && cmp == 0:
 			pkgExists := false
 			for _, existing := range r.packages {
 				if path.Base(existing) == path.Base(pkg) {
 					pkgExists = true
+					dropped.Skip(pkg, "duplicate of %s", existing)
 				}
 			}
 			if !pkgExists {
 				if !backport && r.backport {
 					// discard any backport(s) in favor of the non-backport.
 					// (handles non-backports listed after backports)
-					r = rev{[]string{pkg}, revision, backport}
+					for _, existing := range r.packages {
+						dropped.Skip(existing, "non-supported backport of version %s, the non-backport package is used", version)
+					}
+					r = rev{[]string{pkg}, revision, packageVersion, backport}
 				} else if backport == r.backport {
 					// add missing packages but only of the same backport class
 					// (handles only backports or non-backports listed before backports)
 					r.packages = append(r.packages, pkg)
+				} else {
+					dropped.Skip(pkg, "non-supported backport of version %s, the non-backport package is used", version)
 				}
 			}
-		case found && r.revision < revision:
-			r = rev{[]string{pkg}, revision, backport}
+		case found && cmp < 0:
+			for _, existing := range r.packages {
+				dropped.Skip(existing, "older revision %d of version %s, revision %d is used", r.revision, version, revision)
+			}
+			r = rev{[]string{pkg}, revision, packageVersion, backport}
 		case !found:
-			r = rev{[]string{pkg}, revision, backport}
+			r = rev{[]string{pkg}, revision, packageVersion, backport}
 		}
 
 		versions[version] = r
 	}
 
 	for ver, rev := range versions {
-		// Sanity check, there should always be a pair of packages.
-		if len(rev.packages) != 2 {
-			return nil, fmt.Errorf("version %q (rev %d): unpaired package %v", ver, rev.revision, rev.packages)
-		}
+		// Packages of every architecture are paired with the architecture
+		// independent packages of the same version.
+		for _, pkgPair := range util.SplitArch(rev.packages) {
+			// Sanity check, there should always be a pair of packages.
+			if len(pkgPair) != 2 {
+				return nil, fmt.Errorf("version %q (rev %d): unpaired package %v", ver, rev.revision, pkgPair)
+			}
 
-		manifests = append(manifests, rev.packages)
+			manifests = append(manifests, pkgPair)
+		}
 	}
 
 	return manifests, nil
@@ -354,7 +466,7 @@ func reformatPairs(packages []string) ([][]string, error) {
 //
 // For example:
 // [a, b, c] → [[a], [b], [c]]
-func reformatSingle(packages []string) ([][]string, error) {
+func reformatSingle(packages []string, dropped Dropped) ([][]string, error) {
 	var sets = make([][]string, 0, len(packages))
 
 	for _, pkg := range packages {
@@ -371,7 +483,7 @@ func reformatSingle(packages []string) ([][]string, error) {
 //
 // For example:
 // [foo/kernel-src.tar.gz, bar/kernel-src.tar.gz, foo/kernel-headers.tgz] → [[foo/kernel-src.tar.gz, foo/kernel-headers.tgz], [foo/kernel-src.tar.gz]]
-func reformatCOS(packages []string) ([][]string, error) {
+func reformatCOS(packages []string, dropped Dropped) ([][]string, error) {
 	sort.Slice(packages, func(i, j int) bool {
 		return packages[i] > packages[j]
 	})
@@ -396,41 +508,89 @@ func reformatCOS(packages []string) ([][]string, error) {
 }
 
 // reformatSuse consumes a list of SUSE packages and matches versions
-// between the arch specific (x86_64) and non-archicture specific package.
-func reformatSuse(packages []string) ([][]string, error) {
+// between the arch specific (x86_64 or aarch64) and non-archicture specific
+// package. Only the newest build of each package of a version is used.
+func reformatSuse(packages []string, dropped Dropped) ([][]string, error) {
 	var (
 		manifests = make([][]string, 0, len(packages)/2)
 		versions  = make(map[string][]string)
 	)
 
 	for _, pkg := range packages {
-		matches := suseVersion.FindStringSubmatch(pkg)
-		if len(matches) != 1 {
-			return nil, fmt.Errorf("regex failed to match " + pkg)
+		info, err := pkginfo.Parse(pkg)
+		if err != nil {
+			return nil, err
 		}
 
-		version := matches[0]
+		// Builds of a version differ in the last component of the release,
+		// such as "5.3.18-24.75.3", and only the newest build is used.
+		version := info.KernelRelease
+		if index := strings.LastIndex(version, "."); index > strings.Index(version, "-") {
+			version = version[:index]
+		}
 		if _, found := versions[version]; !found {
 			versions[version] = make([]string, 0, 2)
 		}
 		versions[version] = append(versions[version], pkg)
 	}
 
-	for ver, pkgPair := range versions {
-		// Sanity check, there should always be a pair of packages.
-		if len(pkgPair) != 2 {
-			return nil, fmt.Errorf("version %q: unpaired package %v", ver, pkgPair)
+	for ver, pkgs := range versions {
+		for _, pkgPair := range util.SplitArch(newestRPMs(pkgs, dropped)) {
+			// Sanity check, there should always be a pair of packages.
+			if len(pkgPair) != 2 {
+				return nil, fmt.Errorf("version %q: unpaired package %v", ver, pkgPair)
+			}
+			manifests = append(manifests, pkgPair)
 		}
-		manifests = append(manifests, pkgPair)
 	}
 
 	return manifests, nil
 }
 
-var (
-	minikubeVersionRe       = regexp.MustCompile(`\/v\d+\.\d+\.\d+\/`)
-	minikubeKernelVersionRe = regexp.MustCompile(`(?:kernel=)((\d+)\.\d+\.\d+)`)
-)
+// newestRPMs returns the given RPM packages, but only the newest version of
+// every package name and architecture, as compared by pkginfo.RPMCompare. Packages
+// whose file names can't be parsed are all kept.
+func newestRPMs(packages []string, dropped Dropped) []string {
+	type rpm struct {
+		index int
+		evr   string
+	}
+
+	var (
+		newest  = make(map[string]rpm, len(packages))
+		results = make([]string, 0, len(packages))
+	)
+	for _, pkg := range packages {
+		info, err := pkginfo.Parse(pkg)
+		if err != nil || info.Format != pkginfo.FormatRPM {
+			results = append(results, pkg)
+			continue
+		}
+
+		var (
+			key     = info.Name + "." + info.Arch
+			evr     = info.Version
+			current = rpm{index: len(results), evr: evr}
+		)
+		existing, found := newest[key]
+		switch {
+		case !found:
+			results = append(results, pkg)
+		case pkginfo.RPMCompare(existing.evr, evr) == 0:
+			dropped.Skip(pkg, "duplicate of %s", results[existing.index])
+			continue
+		case pkginfo.RPMLess(existing.evr, evr):
+			dropped.Skip(results[existing.index], "older version %s of package %s, version %s is used", existing.evr, key, evr)
+			results[existing.index] = pkg
+			current.index = existing.index
+		default:
+			dropped.Skip(pkg, "older version %s of package %s, version %s is used", evr, key, existing.evr)
+			continue
+		}
+		newest[key] = current
+	}
+	return results
+}
 
 // reformatMinikube consumes a list of configuration files and will return
 // groups of kernel headers with the configuration to be used for a given
@@ -441,23 +601,23 @@ var (
 // For example:
 // [foo/v.1.24.0/something?kernel=4.19.202, foo/v.1.25.0/something?kernel=4.19.202], [bar/v4.x/linux-4.19.202.tar.xz] ->
 // [[foo/v.1.24.0/something?kernel=4.19.202, bar/v4.x/linux-4.19.202.tar.xz], [foo/v.1.25.0/something?kernel=4.19.202, bar/v4.x/linux-4.19.202.tar.xz]]
-func reformatMinikube(packages []string) ([][]string, error) {
+func reformatMinikube(packages []string, dropped Dropped) ([][]string, error) {
 	versions := make([][]string, 0, len(packages))
 
 	for _, pkg := range packages {
-		kernelVersion := minikubeKernelVersionRe.FindStringSubmatch(pkg)
-		if len(kernelVersion) != 3 {
+		if !strings.Contains(pkg, "kernel=") {
 			return nil, nil
 		}
 
-		minikubeVersion := minikubeVersionRe.FindStringSubmatch(pkg)
-		if minikubeVersion == nil {
+		info, err := pkginfo.Parse(pkg)
+		if err != nil || info.Family != "minikube" {
 			return nil, fmt.Errorf("Failed to match minikube package: %s", pkg)
 		}
+		major := strings.SplitN(info.KernelVersion, ".", 2)[0]
 
 		manifest := make([]string, 0, 2)
 		manifest = append(manifest, pkg)
-		manifest = append(manifest, fmt.Sprintf("https://cdn.kernel.org/pub/linux/kernel/v%s.x/linux-%s.tar.xz", kernelVersion[2], kernelVersion[1]))
+		manifest = append(manifest, fmt.Sprintf("https://cdn.kernel.org/pub/linux/kernel/v%s.x/linux-%s.tar.xz", major, info.KernelVersion))
 
 		versions = append(versions, manifest)
 	}
@@ -14,6 +14,7 @@ func TestReformatPairs(t *testing.T) {
 		title     string
 		packages  []string
 		manifests [][]string
+		dropped   Dropped
 	}{
 		{
 			title:     "empty string",
@@ -72,6 +73,10 @@ func TestReformatPairs(t *testing.T) {
 					"http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-headers-5.4.0-1048-gke_5.4.0-1048.50_amd64.deb",
 				},
 			},
+			dropped: Dropped{
+				"http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-gke-headers-5.4.0-1048_5.4.0-1048.50~18.04.1_amd64.deb": {Reason: "non-supported backport of version 5.4.0-1048, the non-backport package is used", Skipped: true},
+				"http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-headers-5.4.0-1048-gke_5.4.0-1048.50~18.04.1_amd64.deb": {Reason: "non-supported backport of version 5.4.0-1048, the non-backport package is used", Skipped: true},
+			},
 		},
 		{
 			title: "backport debs remain if 16.04",
@@ -107,14 +112,61 @@ func TestReformatPairs(t *testing.T) {
 					"http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-headers-5.4.0-1048-gke_5.4.0-1048.50_amd64.deb",
 				},
 			},
+			dropped: Dropped{
+				"http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-gke-headers-5.4.0-1048_5.4.0-1048.50~18.04.1_amd64.deb": {Reason: "non-supported backport of version 5.4.0-1048, the non-backport package is used", Skipped: true},
+				"http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-headers-5.4.0-1048-gke_5.4.0-1048.50~18.04.1_amd64.deb": {Reason: "non-supported backport of version 5.4.0-1048, the non-backport package is used", Skipped: true},
+			},
+		},
+		{
+			title: "newer revisions replace older revisions",
+			packages: []string{
+				"http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-gke-headers-5.4.0-1048_5.4.0-1048.40_amd64.deb",
+				"http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-gke-headers-5.4.0-1048_5.4.0-1048.50_amd64.deb",
+				"http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-headers-5.4.0-1048-gke_5.4.0-1048.50_amd64.deb",
+				"http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-headers-5.4.0-1048-gke_5.4.0-1048.40_amd64.deb",
+			},
+			manifests: [][]string{
+				{
+					"http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-gke-headers-5.4.0-1048_5.4.0-1048.50_amd64.deb",
+					"http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-headers-5.4.0-1048-gke_5.4.0-1048.50_amd64.deb",
+				},
+			},
+			dropped: Dropped{
+				"http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-gke-headers-5.4.0-1048_5.4.0-1048.40_amd64.deb": {Reason: "older revision 40 of version 5.4.0-1048, revision 50 is used", Skipped: true},
+				"http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-headers-5.4.0-1048-gke_5.4.0-1048.40_amd64.deb": {Reason: "older revision 40 of version 5.4.0-1048, revision 50 is used", Skipped: true},
+			},
+		},
+		{
+			title: "amd64 and arm64 debs",
+			packages: []string{
+				"http://ports.ubuntu.com/ubuntu-ports/pool/main/l/linux-aws/linux-aws-headers-5.4.0-1048_5.4.0-1048.50_all.deb",
+				"http://ports.ubuntu.com/ubuntu-ports/pool/main/l/linux-aws/linux-headers-5.4.0-1048-aws_5.4.0-1048.50_amd64.deb",
+				"http://ports.ubuntu.com/ubuntu-ports/pool/main/l/linux-aws/linux-headers-5.4.0-1048-aws_5.4.0-1048.50_arm64.deb",
+			},
+			manifests: [][]string{
+				{
+					"http://ports.ubuntu.com/ubuntu-ports/pool/main/l/linux-aws/linux-aws-headers-5.4.0-1048_5.4.0-1048.50_all.deb",
+					"http://ports.ubuntu.com/ubuntu-ports/pool/main/l/linux-aws/linux-headers-5.4.0-1048-aws_5.4.0-1048.50_amd64.deb",
+				},
+				{
+					"http://ports.ubuntu.com/ubuntu-ports/pool/main/l/linux-aws/linux-aws-headers-5.4.0-1048_5.4.0-1048.50_all.deb",
+					"http://ports.ubuntu.com/ubuntu-ports/pool/main/l/linux-aws/linux-headers-5.4.0-1048-aws_5.4.0-1048.50_arm64.deb",
+				},
+			},
 		},
 	}
 
 	for index, test := range tests {
 		name := fmt.Sprintf("%d %s", index+1, test.title)
 		t.Run(name, func(t *testing.T) {
-			actual, _ := reformatPairs(test.packages)
+			dropped := make(Dropped)
+			actual, _ := reformatPairs(test.packages, dropped)
 			assert.ElementsMatch(t, test.manifests, actual)
+			if test.dropped == nil {
+				assert.Empty(t, dropped)
+			} else {
+				assert.Equal(t, test.dropped, dropped)
+			}
 		})
 	}
 }
@@ -128,7 +180,7 @@ func TestReformatCOS(t *testing.T) {
 		"https://storage.googleapis.com/cos-tools/13310.1260.26/kernel-headers.tgz",
 	}
 
-	groups, err := reformatCOS(packages)
+	groups, err := reformatCOS(packages, nil)
 	require.NoError(t, err)
 
 	expectedGroups := [][]string{
@@ -162,7 +214,7 @@ func TestReformatSuse(t *testing.T) {
 		"https://updates.suse.com/SUSE/Updates/SLE-Module-Basesystem/15-SP2/x86_64/update/x86_64/kernel-default-devel-5.3.18-24.75.3.x86_64.rpm",
 	}
 
-	groups, err := reformatSuse(packages)
+	groups, err := reformatSuse(packages, nil)
 	require.NoError(t, err)
 
 	expectedGroups := [][]string{
@@ -190,3 +242,25 @@ func TestReformatSuse(t *testing.T) {
 
 	assert.ElementsMatch(t, expectedGroups, groups)
 }
+
+func TestReformatSuseNewestBuild(t *testing.T) {
+	packages := []string{
+		"https://updates.suse.com/SUSE/Updates/SLE-Module-Basesystem/15-SP2/x86_64/update/noarch/kernel-devel-5.3.18-24.75.2.noarch.rpm",
+		"https://updates.suse.com/SUSE/Updates/SLE-Module-Basesystem/15-SP2/x86_64/update/x86_64/kernel-default-devel-5.3.18-24.75.10.x86_64.rpm",
+		"https://updates.suse.com/SUSE/Updates/SLE-Module-Basesystem/15-SP2/x86_64/update/x86_64/kernel-default-devel-5.3.18-24.75.3.x86_64.rpm",
+	}
+
+	dropped := make(Dropped)
+	groups, err := reformatSuse(packages, dropped)
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]string{
+		{
+			"https://updates.suse.com/SUSE/Updates/SLE-Module-Basesystem/15-SP2/x86_64/update/noarch/kernel-devel-5.3.18-24.75.2.noarch.rpm",
+			"https://updates.suse.com/SUSE/Updates/SLE-Module-Basesystem/15-SP2/x86_64/update/x86_64/kernel-default-devel-5.3.18-24.75.10.x86_64.rpm",
+		},
+	}, groups)
+	assert.Equal(t, Dropped{
+		"https://updates.suse.com/SUSE/Updates/SLE-Module-Basesystem/15-SP2/x86_64/update/x86_64/kernel-default-devel-5.3.18-24.75.3.x86_64.rpm": {Reason: "older version 5.3.18-24.75.3 of package kernel-default-devel.x86_64, version 5.3.18-24.75.10 is used", Skipped: true},
+	}, dropped)
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/stackrox/kernel-packer/tools/config/images"
+	"github.com/stackrox/kernel-packer/tools/config/reformat"
+	"github.com/stackrox/kernel-packer/tools/generate-manifest/reformatters"
+	"github.com/stackrox/kernel-packer/tools/util"
+)
+
+// distroCaseRegex matches the labels of the case statement that selects how
+// the packer entrypoint repackages each distro, such as "        redhat)".
+var distroCaseRegex = regexp.MustCompile(`^\s*([a-z0-9_-]+)\)\s*$`)
+
+// validateCmd is run by the validate subcommand. It checks the reformat config,
+// every package list that it names, and the image config, and prints every
+// problem found.
+func validateCmd(args []string) error {
+	var (
+		flags         = flag.NewFlagSet("validate", flag.ExitOnError)
+		configFlag    = flags.String("config", "reformat.yml", "Config file containing reformat manifest.")
+		imagesFlag    = flags.String("images", "images.yml", "Config file containing packer image selection rules.")
+		packerDirFlag = flags.String("packer-dir", "packers", "Path to packer sources, used to find the supported distros.")
+	)
+	flags.Parse(args)
+
+	cfg, err := reformat.Load(*configFlag)
+	if err != nil {
+		return err
+	}
+
+	distros, err := supportedDistros(path.Join(*packerDirFlag, "entrypoint"))
+	if err != nil {
+		return err
+	}
+
+	imageConfig, err := images.Load(*imagesFlag)
+	if err != nil {
+		return err
+	}
+
+	problems := validateConfig(*cfg, path.Dir(*configFlag), distros)
+	problems = append(problems, validateImageConfig(*imageConfig, *cfg, distros)...)
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+
+	if len(problems) > 0 {
+		return errors.Errorf("found %d problems in %s and %s", len(problems), *configFlag, *imagesFlag)
+	}
+	return nil
+}
+
+// supportedDistros returns the distros that the given packer entrypoint can
+// repackage.
+func supportedDistros(filename string) (map[string]struct{}, error) {
+	body, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		distros = make(map[string]struct{})
+		inCase  bool
+	)
+	for _, line := range strings.Split(string(body), "\n") {
+		switch {
+		case strings.Contains(line, `case "$distro" in`):
+			inCase = true
+		case inCase && strings.TrimSpace(line) == "esac":
+			inCase = false
+		case inCase:
+			if matches := distroCaseRegex.FindStringSubmatch(line); matches != nil {
+				distros[matches[1]] = struct{}{}
+			}
+		}
+	}
+
+	if len(distros) == 0 {
+		return nil, errors.Errorf("no distros found in %s", filename)
+	}
+	return distros, nil
+}
+
+// validateConfig checks every entry of the given config, and returns a
+// description of every problem found. Package lists are read relative to the
+// given config directory.
+func validateConfig(cfg reformat.Config, configDir string, distros map[string]struct{}) []string {
+	var (
+		problems []string
+		names    = make(map[string]struct{}, len(cfg))
+	)
+
+	for index, entry := range cfg {
+		var (
+			name    = entry.Name
+			reportf = func(format string, args ...interface{}) {
+				problems = append(problems, fmt.Sprintf("%s: %s", name, fmt.Sprintf(format, args...)))
+			}
+		)
+		if name == "" {
+			name = fmt.Sprintf("entry %d", index+1)
+			reportf("name is missing")
+		} else if _, found := names[name]; found {
+			reportf("name is not unique")
+		}
+		names[entry.Name] = struct{}{}
+
+		if _, found := distros[entry.Type]; !found {
+			reportf("type %q is not supported by the packer entrypoint", entry.Type)
+		}
+
+		reformatter, err := reformatters.Get(entry.Reformat)
+		if err != nil {
+			reportf("reformat %q is not a registered reformatter", entry.Reformat)
+		}
+
+		if entry.File == "" {
+			reportf("file is missing")
+			continue
+		}
+		lines, err := readPackageLines(path.Join(configDir, entry.File))
+		if err != nil {
+			reportf("%v", err)
+			continue
+		}
+
+		for _, problem := range validatePackageList(lines) {
+			reportf("%s: %s", entry.File, problem)
+		}
+
+		if reformatter == nil {
+			continue
+		}
+		urls, err := readPackagesFile(path.Join(configDir, entry.File))
+		if err != nil {
+			reportf("%v", err)
+			continue
+		}
+		unconsumed, err := unconsumedURLs(entry, reformatter, urls)
+		if err != nil {
+			reportf("%s: %v", entry.File, err)
+			continue
+		}
+		for _, url := range unconsumed {
+			reportf("%s: %s is not consumed by the %s reformatter", entry.File, url, entry.Reformat)
+		}
+	}
+
+	return problems
+}
+
+// validateImageConfig checks that the rules of the given image config are for
+// supported kinds, and for entries of the given reformat config, and returns a
+// description of every problem found.
+func validateImageConfig(imageConfig images.Config, cfg reformat.Config, distros map[string]struct{}) []string {
+	var (
+		problems []string
+		names    = make(map[string]struct{}, len(cfg))
+	)
+	for _, entry := range cfg {
+		names[entry.Name] = struct{}{}
+	}
+
+	for _, kind := range sortedKeys(imageConfig.Kinds) {
+		if _, found := distros[kind]; !found {
+			problems = append(problems, fmt.Sprintf("images: kind %q is not supported by the packer entrypoint", kind))
+		}
+	}
+	for _, name := range sortedKeys(imageConfig.Entries) {
+		if _, found := names[name]; !found {
+			problems = append(problems, fmt.Sprintf("images: entry %q is not in the reformat config", name))
+		}
+	}
+
+	return problems
+}
+
+func sortedKeys(rules map[string]images.Rules) []string {
+	keys := make([]string, 0, len(rules))
+	for key := range rules {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// readPackageLines reads the given package list, and returns every line,
+// including empty lines. Trailing whitespace of the file is ignored.
+func readPackageLines(filename string) ([]string, error) {
+	body, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	body = bytes.TrimRight(body, " \t\r\n")
+	if len(body) == 0 {
+		return nil, nil
+	}
+	return strings.Split(string(body), "\n"), nil
+}
+
+// validatePackageList checks the given lines of a package list, and returns a
+// description of every problem found. Every line must hold a single http or
+// https URL, that is listed once.
+func validatePackageList(lines []string) []string {
+	var (
+		problems []string
+		seen     = make(map[string]int, len(lines))
+	)
+
+	for index, line := range lines {
+		var (
+			lineNumber = index + 1
+			trimmed    = strings.TrimSpace(line)
+		)
+
+		switch {
+		case trimmed == "":
+			problems = append(problems, fmt.Sprintf("line %d: empty line", lineNumber))
+			continue
+		case strings.HasPrefix(trimmed, "#"):
+			problems = append(problems, fmt.Sprintf("line %d: comments are not supported", lineNumber))
+			continue
+		case trimmed != line:
+			problems = append(problems, fmt.Sprintf("line %d: leading or trailing whitespace", lineNumber))
+		}
+
+		if first, found := seen[trimmed]; found {
+			problems = append(problems, fmt.Sprintf("line %d: duplicate of line %d", lineNumber, first))
+			continue
+		}
+		seen[trimmed] = lineNumber
+
+		if err := validateURL(trimmed); err != nil {
+			problems = append(problems, fmt.Sprintf("line %d: %v", lineNumber, err))
+		}
+	}
+
+	return problems
+}
+
+// validateURL checks that the given package list line is an absolute http or
+// https URL.
+func validateURL(line string) error {
+	if strings.ContainsAny(line, " \t") {
+		return errors.Errorf("malformed URL %q: contains whitespace", line)
+	}
+
+	u, err := url.Parse(util.NormalizeURL(line))
+	if err != nil {
+		return errors.Errorf("malformed URL %q", line)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.Errorf("malformed URL %q: scheme must be http or https", line)
+	}
+	if u.Host == "" || u.Path == "" || u.Path == "/" {
+		return errors.Errorf("malformed URL %q: host and path are required", line)
+	}
+	return nil
+}
+
+// unconsumedURLs runs the given reformatter over the given package URLs of the
+// given entry, as generate-manifest does, and returns the URLs that are in no
+// package set. URLs that are skipped on purpose, such as older revisions or by
+// the options of the entry, are consumed. URLs that are dropped as unusable,
+// such as for not matching any package name, are not.
+func unconsumedURLs(entry reformat.Entry, reformatter reformatters.ReformatterFunc, urls []string) ([]string, error) {
+	var dropped = make(reformatters.Dropped)
+	packageSets, err := reformatEntry(entry, reformatter, urls, dropped)
+	if err != nil {
+		return nil, err
+	}
+
+	consumed := make(map[string]struct{}, len(urls))
+	for _, packages := range packageSets {
+		for _, pkg := range packages {
+			consumed[pkg] = struct{}{}
+		}
+	}
+
+	var results []string
+	for _, url := range urls {
+		url = util.NormalizeURL(url)
+		if _, found := consumed[url]; found {
+			continue
+		}
+		if drop := dropped[url]; drop.Skipped {
+			continue
+		}
+		results = append(results, url)
+	}
+	return results, nil
+}
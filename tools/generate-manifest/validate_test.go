@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stackrox/kernel-packer/tools/config/images"
+	"github.com/stackrox/kernel-packer/tools/config/reformat"
+	"github.com/stackrox/kernel-packer/tools/generate-manifest/reformatters"
+)
+
+func TestValidatePackageList(t *testing.T) {
+	tests := []struct {
+		title    string
+		lines    []string
+		expected []string
+	}{
+		{
+			title: "valid",
+			lines: []string{
+				"https://mirrors.kernel.org/centos/7/x86_64/kernel-devel-3.10.0-1160.el7.x86_64.rpm",
+				"http://security.ubuntu.com/ubuntu/pool/main/l/linux/linux-headers-5.4.0-100_5.4.0-100.113_all.deb",
+			},
+		},
+		{
+			title: "duplicate",
+			lines: []string{
+				"https://mirrors.kernel.org/centos/7/x86_64/kernel-devel-3.10.0-1160.el7.x86_64.rpm",
+				"https://mirrors.kernel.org/centos/7/x86_64/kernel-devel-3.10.0-1160.el7.x86_64.rpm",
+			},
+			expected: []string{"line 2: duplicate of line 1"},
+		},
+		{
+			title: "comment and empty line",
+			lines: []string{
+				"# hotfix kernels",
+				"",
+				"https://mirrors.kernel.org/centos/7/x86_64/kernel-devel-3.10.0-1160.el7.x86_64.rpm",
+			},
+			expected: []string{
+				"line 1: comments are not supported",
+				"line 2: empty line",
+			},
+		},
+		{
+			title: "malformed",
+			lines: []string{
+				"ftp://mirrors.kernel.org/kernel-devel.rpm",
+				"https:///kernel-devel.rpm",
+				"https://mirrors.kernel.org/kernel devel.rpm",
+				" https://mirrors.kernel.org/kernel-devel.rpm",
+			},
+			expected: []string{
+				`line 1: malformed URL "ftp://mirrors.kernel.org/kernel-devel.rpm": scheme must be http or https`,
+				`line 2: malformed URL "https:///kernel-devel.rpm": host and path are required`,
+				`line 3: malformed URL "https://mirrors.kernel.org/kernel devel.rpm": contains whitespace`,
+				"line 4: leading or trailing whitespace",
+			},
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, validatePackageList(test.lines))
+		})
+	}
+}
+
+func TestUnconsumedURLs(t *testing.T) {
+	tests := []struct {
+		title    string
+		reformat string
+		entry    reformat.Entry
+		urls     []string
+		expected []string
+	}{
+		{
+			title:    "all consumed",
+			reformat: "single",
+			urls: []string{
+				"https://mirrors.kernel.org/centos/7/x86_64/kernel-devel-3.10.0-1160.el7.x86_64.rpm",
+			},
+		},
+		{
+			title:    "older revision is consumed",
+			reformat: "pairs",
+			urls: []string{
+				"http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-gke-headers-5.4.0-1048_5.4.0-1048.40_amd64.deb",
+				"http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-gke-headers-5.4.0-1048_5.4.0-1048.50_amd64.deb",
+				"http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-headers-5.4.0-1048-gke_5.4.0-1048.50_amd64.deb",
+			},
+		},
+		{
+			title:    "excluded is consumed",
+			reformat: "single",
+			entry:    reformat.Entry{Exclude: []string{`\.el7\.`}},
+			urls: []string{
+				"https://mirrors.kernel.org/centos/7/x86_64/kernel-devel-3.10.0-1160.el7.x86_64.rpm",
+			},
+		},
+		{
+			title:    "duplicate is consumed",
+			reformat: "suse",
+			urls: []string{
+				"https://updates.suse.com/SUSE/Updates/SLE-Module-Basesystem/15-SP2/x86_64/update/noarch/kernel-devel-5.3.18-24.75.2.noarch.rpm",
+				"https://updates.suse.com/SUSE/Updates/SLE-Module-Basesystem/15-SP2/x86_64/update/x86_64/kernel-default-devel-5.3.18-24.75.3.x86_64.rpm",
+				"https://updates.suse.com/SUSE/Products/SLE-Module-Basesystem/15-SP2/x86_64/update/x86_64/kernel-default-devel-5.3.18-24.75.3.x86_64.rpm",
+			},
+		},
+		{
+			title:    "unmatched debian package",
+			reformat: "debian",
+			urls: []string{
+				"http://security.debian.org/pool/updates/main/l/linux/linux-kbuild-4.19_4.19.249-2_amd64.deb",
+				"http://security.debian.org/pool/updates/main/l/linux/linux-headers-4.19.0-21-common_4.19.249-2_all.deb",
+				"http://security.debian.org/pool/updates/main/l/linux/linux-headers-4.19.0-21-amd64_4.19.249-2_amd64.deb",
+				"http://security.debian.org/pool/updates/main/l/linux/linux-image-4.19.0-21-amd64_4.19.249-2_amd64.deb",
+			},
+			expected: []string{
+				"http://security.debian.org/pool/updates/main/l/linux/linux-image-4.19.0-21-amd64_4.19.249-2_amd64.deb",
+			},
+		},
+		{
+			title:    "minikube without kernel version",
+			reformat: "minikube",
+			urls: []string{
+				"https://raw.githubusercontent.com/kubernetes/minikube/v1.29.0/deploy/iso/minikube-iso/board/minikube/x86_64/linux_x86_64_defconfig",
+			},
+			expected: []string{
+				"https://raw.githubusercontent.com/kubernetes/minikube/v1.29.0/deploy/iso/minikube-iso/board/minikube/x86_64/linux_x86_64_defconfig",
+			},
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			reformatter, err := reformatters.Get(test.reformat)
+			require.NoError(t, err)
+			require.NoError(t, test.entry.Compile())
+
+			actual, err := unconsumedURLs(test.entry, reformatter, test.urls)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestValidateImageConfig(t *testing.T) {
+	var (
+		cfg         = reformat.Config{{Name: "ubuntu-gke", Type: "ubuntu"}}
+		distros     = map[string]struct{}{"ubuntu": {}, "debian": {}}
+		imageConfig = images.Config{
+			Kinds: map[string]images.Rules{
+				"ubuntu": {Default: "repackage-bookworm"},
+				"fedora": {Default: "repackage-bookworm"},
+			},
+			Entries: map[string]images.Rules{
+				"ubuntu-gke": {Default: "repackage-bookworm"},
+				"ubuntu-eks": {Default: "repackage-bookworm"},
+			},
+		}
+	)
+
+	assert.Equal(t, []string{
+		`images: kind "fedora" is not supported by the packer entrypoint`,
+		`images: entry "ubuntu-eks" is not in the reformat config`,
+	}, validateImageConfig(imageConfig, cfg, distros))
+}
+
+func TestSupportedDistros(t *testing.T) {
+	distros, err := supportedDistros("../../packers/entrypoint")
+	require.NoError(t, err)
+
+	for _, distro := range []string{"coreos", "cos", "debian", "garden", "minikube", "oracle", "redhat", "suse", "ubuntu"} {
+		assert.Contains(t, distros, distro)
+	}
+	assert.NotContains(t, distros, "esac")
+}
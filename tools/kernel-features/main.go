@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+
+	"github.com/stackrox/kernel-packer/tools/bundle"
+)
+
+func main() {
+	if err := mainCmd(); err != nil {
+		fmt.Fprintf(os.Stderr, "kernel-features: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+// kernelFeatures maps bundle versions, such as "4.18.0-305.el8.x86_64", to
+// the features detected in each bundle.
+type kernelFeatures map[string]map[string]bool
+
+func mainCmd() error {
+	var (
+		flagOutput = flag.String("output", "", "Path to the kernel features file to merge the results into.")
+		flagTee    = flag.Bool("tee", false, "Also print the results to stdout when writing an output file.")
+		flagJobs   = flag.Int("jobs", runtime.NumCPU(), "Number of bundles to read in parallel.")
+		flagFormat = flag.String("format", "json", "Format of the results printed to stdout. (one of json, or table)")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] bundle-dir|bundle.tgz...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return errors.New("no bundles given")
+	}
+	if *flagJobs < 1 {
+		return errors.New("jobs must be at least 1")
+	}
+	if *flagFormat != "json" && *flagFormat != "table" {
+		return errors.Errorf("unknown format %q", *flagFormat)
+	}
+
+	filenames, err := findBundles(flag.Args())
+	if err != nil {
+		return err
+	}
+
+	kernels, failed := detectFeatures(filenames, *flagJobs)
+
+	if *flagOutput != "" {
+		if err := mergeFeatures(*flagOutput, kernels); err != nil {
+			return err
+		}
+	}
+
+	if *flagOutput == "" || *flagTee {
+		var err error
+		if *flagFormat == "table" {
+			err = printTable(os.Stdout, kernels)
+		} else {
+			err = json.NewEncoder(os.Stdout).Encode(kernels)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return errors.Errorf("failed to read %d of %d bundles", failed, len(filenames))
+	}
+	return nil
+}
+
+// findBundles returns every bundle in the given paths. Directories are
+// searched recursively for .tgz files.
+func findBundles(paths []string) ([]string, error) {
+	var filenames []string
+
+	for _, root := range paths {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if path == root && !info.IsDir() {
+				filenames = append(filenames, path)
+			} else if !info.IsDir() && filepath.Ext(path) == ".tgz" {
+				filenames = append(filenames, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return filenames, nil
+}
+
+// detectFeatures reads the given bundles, using the given number of parallel
+// jobs, and returns the features detected in each. Bundles that cannot be
+// read are reported, and counted.
+func detectFeatures(filenames []string, jobs int) (kernelFeatures, int) {
+	var (
+		kernels = make(kernelFeatures)
+		failed  int
+		mutex   sync.Mutex
+		wg      sync.WaitGroup
+		queue   = make(chan string)
+	)
+
+	for worker := 0; worker < jobs; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for filename := range queue {
+				b, err := bundle.Open(filename)
+
+				mutex.Lock()
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "kernel-features: %s: %v\n", filename, err)
+					failed++
+				} else {
+					kernels[bundleVersion(filename)] = b.Features()
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, filename := range filenames {
+		queue <- filename
+	}
+	close(queue)
+	wg.Wait()
+
+	return kernels, failed
+}
+
+// bundleVersion returns the version that a bundle is listed under, taken
+// from its filename, such that "bundle-4.18.0-305.el8.x86_64.tgz" becomes
+// "4.18.0-305.el8.x86_64".
+func bundleVersion(filename string) string {
+	var name = filepath.Base(filename)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	return strings.TrimPrefix(name, "bundle-")
+}
+
+// mergeFeatures merges the given kernel features into the given kernel
+// features file, replacing the entries of any bundles that are already
+// listed. A missing file is created.
+func mergeFeatures(filename string, kernels kernelFeatures) error {
+	var existing = make(kernelFeatures)
+
+	body, err := ioutil.ReadFile(filename)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(body, &existing); err != nil {
+			return errors.Wrapf(err, "failed to parse %s", filename)
+		}
+	}
+
+	for version, features := range kernels {
+		existing[version] = features
+	}
+
+	body, err = json.Marshal(existing)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, body, 0644)
+}
+
+// printTable prints the given kernel features as a capability matrix, with a
+// row for each bundle, and a column for each feature.
+func printTable(w io.Writer, kernels kernelFeatures) error {
+	var (
+		versions []string
+		features []string
+		tw       = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	)
+
+	for version := range kernels {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	for _, detector := range bundle.Detectors() {
+		features = append(features, detector.Feature())
+	}
+
+	fmt.Fprintf(tw, "BUNDLE\t%s\n", strings.Join(features, "\t"))
+	for _, version := range versions {
+		var row = []string{version}
+		for _, feature := range features {
+			if kernels[version][feature] {
+				row = append(row, "yes")
+			} else {
+				row = append(row, "-")
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	return tw.Flush()
+}
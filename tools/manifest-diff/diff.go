@@ -0,0 +1,247 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/stackrox/kernel-packer/tools/config/manifest"
+	"github.com/stackrox/kernel-packer/tools/pkginfo"
+)
+
+// manifestDiff is the difference between two manifests.
+type manifestDiff struct {
+	// Added are the builders that are only in the new manifest, and that do
+	// not replace a builder for the same kernel.
+	Added []builderChange `json:"added"`
+
+	// Removed are the builders that are only in the old manifest, and that
+	// are not replaced by a builder for the same kernel.
+	Removed []builderChange `json:"removed"`
+
+	// Images are the builders in both manifests that changed image.
+	Images []imageChange `json:"images"`
+
+	// Packages are the kernels whose set of packages changed.
+	Packages []packageChange `json:"packages"`
+}
+
+// builderChange is a builder that was added or removed.
+type builderChange struct {
+	ID            string   `json:"id"`
+	Kind          string   `json:"kind"`
+	KernelVersion string   `json:"kernelVersion"`
+	Arch          string   `json:"arch,omitempty"`
+	Image         string   `json:"image,omitempty"`
+	Packages      []string `json:"packages"`
+}
+
+// imageChange is a builder whose image changed.
+type imageChange struct {
+	ID            string `json:"id"`
+	Kind          string `json:"kind"`
+	KernelVersion string `json:"kernelVersion"`
+	OldImage      string `json:"oldImage"`
+	NewImage      string `json:"newImage"`
+}
+
+// packageChange is a kernel that is built from a different set of packages.
+type packageChange struct {
+	Kind          string   `json:"kind"`
+	KernelVersion string   `json:"kernelVersion"`
+	Arch          string   `json:"arch,omitempty"`
+	OldIDs        []string `json:"oldIds"`
+	NewIDs        []string `json:"newIds"`
+	Removed       []string `json:"removed"`
+	Added         []string `json:"added"`
+}
+
+// kernelKey identifies the kernel that a builder builds.
+type kernelKey struct {
+	kind    string
+	version string
+	arch    string
+}
+
+// Empty reports whether the manifests are the same.
+func (d manifestDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Images) == 0 && len(d.Packages) == 0
+}
+
+// diffManifests compares the given manifests. Builders are matched by id.
+// Builders that were added and removed for the same kind, kernel version and
+// architecture are reported as a package change, rather than as additions and removals.
+func diffManifests(oldManifest, newManifest manifest.Manifest) manifestDiff {
+	var (
+		diff = manifestDiff{
+			Added:    []builderChange{},
+			Removed:  []builderChange{},
+			Images:   []imageChange{},
+			Packages: []packageChange{},
+		}
+		added   = make(map[kernelKey][]string)
+		removed = make(map[kernelKey][]string)
+	)
+
+	for _, id := range newManifest.SortedIDs() {
+		builder := newManifest[id]
+		key := builderKey(builder)
+
+		oldBuilder, found := oldManifest[id]
+		if !found {
+			added[key] = append(added[key], id)
+			continue
+		}
+		if oldBuilder.Image != builder.Image {
+			diff.Images = append(diff.Images, imageChange{
+				ID:            id,
+				Kind:          key.kind,
+				KernelVersion: key.version,
+				OldImage:      oldBuilder.Image,
+				NewImage:      builder.Image,
+			})
+		}
+	}
+
+	for _, id := range oldManifest.SortedIDs() {
+		if _, found := newManifest[id]; !found {
+			key := builderKey(oldManifest[id])
+			removed[key] = append(removed[key], id)
+		}
+	}
+
+	for key, newIDs := range added {
+		oldIDs, found := removed[key]
+		if !found || key.version == "" {
+			for _, id := range newIDs {
+				diff.Added = append(diff.Added, newBuilderChange(id, key, newManifest[id]))
+			}
+			continue
+		}
+
+		oldPackages := manifestPackages(oldManifest, oldIDs)
+		newPackages := manifestPackages(newManifest, newIDs)
+		diff.Packages = append(diff.Packages, packageChange{
+			Kind:          key.kind,
+			KernelVersion: key.version,
+			Arch:          key.arch,
+			OldIDs:        oldIDs,
+			NewIDs:        newIDs,
+			Removed:       subtract(oldPackages, newPackages),
+			Added:         subtract(newPackages, oldPackages),
+		})
+		delete(removed, key)
+	}
+
+	for key, oldIDs := range removed {
+		for _, id := range oldIDs {
+			diff.Removed = append(diff.Removed, newBuilderChange(id, key, oldManifest[id]))
+		}
+	}
+
+	sortBuilderChanges(diff.Added)
+	sortBuilderChanges(diff.Removed)
+	sort.SliceStable(diff.Images, func(i, j int) bool {
+		return kernelLess(
+			kernelKey{kind: diff.Images[i].Kind, version: diff.Images[i].KernelVersion},
+			kernelKey{kind: diff.Images[j].Kind, version: diff.Images[j].KernelVersion},
+		)
+	})
+	sort.Slice(diff.Packages, func(i, j int) bool {
+		return kernelLess(
+			kernelKey{diff.Packages[i].Kind, diff.Packages[i].KernelVersion, diff.Packages[i].Arch},
+			kernelKey{diff.Packages[j].Kind, diff.Packages[j].KernelVersion, diff.Packages[j].Arch},
+		)
+	})
+
+	return diff
+}
+
+func newBuilderChange(id string, key kernelKey, builder manifest.Builder) builderChange {
+	return builderChange{
+		ID:            id,
+		Kind:          key.kind,
+		KernelVersion: key.version,
+		Arch:          key.arch,
+		Image:         builder.Image,
+		Packages:      builder.Packages,
+	}
+}
+
+// builderKey returns the kind, kernel version and architecture of the given
+// builder.
+func builderKey(builder manifest.Builder) kernelKey {
+	return kernelKey{
+		kind:    builder.Kind,
+		version: kernelVersion(builder),
+		arch:    builder.Arch,
+	}
+}
+
+// kernelVersion returns the kernel release of the given builder, such as
+// "5.4.0-1048-gke". Builders of manifests that predate the kernel release
+// have it parsed from their packages. Builders whose packages name no kernel
+// release, such as those of COS and Flatcar, are identified by the OS release
+// of their packages instead. An empty string is returned if no package names
+// either.
+func kernelVersion(builder manifest.Builder) string {
+	if builder.Kernel != "" {
+		return builder.Kernel
+	}
+	if kernel := manifest.NewBuilder(builder.Kind, builder.Arch, builder.Packages).Kernel; kernel != "" {
+		return kernel
+	}
+
+	for _, pkg := range builder.Packages {
+		if info, err := pkginfo.Parse(pkg); err == nil && info.KernelVersion == "" && info.Revision != "" {
+			return info.Revision
+		}
+	}
+	return ""
+}
+
+// manifestPackages returns the packages of the given builders, sorted.
+func manifestPackages(m manifest.Manifest, ids []string) []string {
+	var packages []string
+	for _, id := range ids {
+		packages = append(packages, m[id].Packages...)
+	}
+	sort.Strings(packages)
+	return packages
+}
+
+// subtract returns the packages of a that are not in b.
+func subtract(a, b []string) []string {
+	var (
+		exclude = make(map[string]struct{}, len(b))
+		results = []string{}
+	)
+	for _, pkg := range b {
+		exclude[pkg] = struct{}{}
+	}
+	for _, pkg := range a {
+		if _, found := exclude[pkg]; !found {
+			results = append(results, pkg)
+		}
+	}
+	return results
+}
+
+func sortBuilderChanges(changes []builderChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		a := kernelKey{changes[i].Kind, changes[i].KernelVersion, changes[i].Arch}
+		b := kernelKey{changes[j].Kind, changes[j].KernelVersion, changes[j].Arch}
+		if a != b {
+			return kernelLess(a, b)
+		}
+		return changes[i].ID < changes[j].ID
+	})
+}
+
+func kernelLess(a, b kernelKey) bool {
+	if a.kind != b.kind {
+		return a.kind < b.kind
+	}
+	if a.version != b.version {
+		return a.version < b.version
+	}
+	return a.arch < b.arch
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stackrox/kernel-packer/tools/config/manifest"
+)
+
+const (
+	rhelPackage       = "https---mirrors.kernel.org-centos-7.9.2009-updates-x86_64-Packages-kernel-devel-3.10.0-1160.80.1.el7.x86_64.rpm"
+	rhelPackageMirror = "https---vault.centos.org-7.9.2009-updates-x86_64-Packages-kernel-devel-3.10.0-1160.80.1.el7.x86_64.rpm"
+	ubuntuPackage     = "http---security.ubuntu.com-ubuntu-pool-main-l-linux-gcp-5.8-linux-headers-5.8.0-1036-gcp_5.8.0-1036.38-20.04.1_amd64.deb"
+	ubuntuCommon      = "http---security.ubuntu.com-ubuntu-pool-main-l-linux-gcp-5.8-linux-gcp-5.8-headers-5.8.0-1036_5.8.0-1036.38-20.04.1_amd64.deb"
+	cosPackage        = "https---storage.googleapis.com-cos-tools-12739.68.0-kernel-headers.tgz"
+)
+
+func TestKernelVersion(t *testing.T) {
+	tests := []struct {
+		title    string
+		packages []string
+		kernel   string
+		expected string
+	}{
+		{
+			title:    "rpm",
+			packages: []string{rhelPackage},
+			expected: "3.10.0-1160.80.1.el7.x86_64",
+		},
+		{
+			title:    "deb with flavour",
+			packages: []string{ubuntuCommon, ubuntuPackage},
+			expected: "5.8.0-1036-gcp",
+		},
+		{
+			title:    "minikube",
+			packages: []string{"https---cdn.kernel.org-pub-linux-kernel-v5.x-linux-5.10.57.tar.xz"},
+			expected: "5.10.57",
+		},
+		{
+			title:    "cos",
+			packages: []string{cosPackage},
+			expected: "12739.68.0",
+		},
+		{
+			title:    "flatcar",
+			packages: []string{"https---stable.release.flatcar-linux.net-amd64-usr-3510.2.0-flatcar_developer_container.bin.bz2"},
+			expected: "3510.2.0",
+		},
+		{
+			title:    "recorded kernel",
+			packages: []string{"https---api.access.redhat.com-management-v1-packages-66ed90931c6a668b3ae3fa4e5bce7c1b-download"},
+			kernel:   "4.18.0-305.el8.x86_64",
+			expected: "4.18.0-305.el8.x86_64",
+		},
+		{
+			title:    "no version",
+			packages: []string{"https---api.access.redhat.com-management-v1-packages-66ed90931c6a668b3ae3fa4e5bce7c1b-download"},
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			builder := manifest.Builder{Packages: test.packages, Kernel: test.kernel}
+			assert.Equal(t, test.expected, kernelVersion(builder))
+		})
+	}
+}
+
+func TestDiffManifests(t *testing.T) {
+	tests := []struct {
+		title       string
+		oldManifest manifest.Manifest
+		newManifest manifest.Manifest
+		expected    manifestDiff
+	}{
+		{
+			title: "same",
+			oldManifest: manifest.Manifest{
+				"a": {Kind: "redhat", Packages: []string{rhelPackage}},
+			},
+			newManifest: manifest.Manifest{
+				"a": {Kind: "redhat", Packages: []string{rhelPackage}},
+			},
+			expected: manifestDiff{},
+		},
+		{
+			title: "added and removed",
+			oldManifest: manifest.Manifest{
+				"a": {Kind: "redhat", Packages: []string{rhelPackage}},
+			},
+			newManifest: manifest.Manifest{
+				"b": {Kind: "cos", Packages: []string{cosPackage}},
+				"c": {Kind: "ubuntu", Image: "repackage-bookworm", Packages: []string{ubuntuPackage}},
+			},
+			expected: manifestDiff{
+				Added: []builderChange{
+					{ID: "b", Kind: "cos", KernelVersion: "12739.68.0", Packages: []string{cosPackage}},
+					{ID: "c", Kind: "ubuntu", KernelVersion: "5.8.0-1036-gcp", Image: "repackage-bookworm", Packages: []string{ubuntuPackage}},
+				},
+				Removed: []builderChange{
+					{ID: "a", Kind: "redhat", KernelVersion: "3.10.0-1160.80.1.el7.x86_64", Packages: []string{rhelPackage}},
+				},
+			},
+		},
+		{
+			title: "image changed",
+			oldManifest: manifest.Manifest{
+				"c": {Kind: "ubuntu", Packages: []string{ubuntuPackage}},
+			},
+			newManifest: manifest.Manifest{
+				"c": {Kind: "ubuntu", Image: "repackage-bookworm", Packages: []string{ubuntuPackage}},
+			},
+			expected: manifestDiff{
+				Images: []imageChange{
+					{ID: "c", Kind: "ubuntu", KernelVersion: "5.8.0-1036-gcp", NewImage: "repackage-bookworm"},
+				},
+			},
+		},
+		{
+			title: "packages changed",
+			oldManifest: manifest.Manifest{
+				"c": {Kind: "ubuntu", Packages: []string{ubuntuPackage}},
+			},
+			newManifest: manifest.Manifest{
+				"d": {Kind: "ubuntu", Packages: []string{ubuntuCommon, ubuntuPackage}},
+			},
+			expected: manifestDiff{
+				Packages: []packageChange{
+					{
+						Kind:          "ubuntu",
+						KernelVersion: "5.8.0-1036-gcp",
+						OldIDs:        []string{"c"},
+						NewIDs:        []string{"d"},
+						Removed:       []string{},
+						Added:         []string{ubuntuCommon},
+					},
+				},
+			},
+		},
+		{
+			title: "same version of another kind",
+			oldManifest: manifest.Manifest{
+				"a": {Kind: "redhat", Packages: []string{rhelPackage}},
+			},
+			newManifest: manifest.Manifest{
+				"e": {Kind: "oracle", Packages: []string{rhelPackageMirror}},
+			},
+			expected: manifestDiff{
+				Added: []builderChange{
+					{ID: "e", Kind: "oracle", KernelVersion: "3.10.0-1160.80.1.el7.x86_64", Packages: []string{rhelPackageMirror}},
+				},
+				Removed: []builderChange{
+					{ID: "a", Kind: "redhat", KernelVersion: "3.10.0-1160.80.1.el7.x86_64", Packages: []string{rhelPackage}},
+				},
+			},
+		},
+		{
+			title: "one of many builders of a version changed",
+			oldManifest: manifest.Manifest{
+				"a": {Kind: "redhat", Packages: []string{rhelPackage}},
+				"f": {Kind: "redhat", Packages: []string{"kernel-devel-3.10.0-1160.80.1.el7.x86_64.rpm"}},
+			},
+			newManifest: manifest.Manifest{
+				"e": {Kind: "redhat", Packages: []string{rhelPackageMirror}},
+				"f": {Kind: "redhat", Packages: []string{"kernel-devel-3.10.0-1160.80.1.el7.x86_64.rpm"}},
+			},
+			expected: manifestDiff{
+				Packages: []packageChange{
+					{
+						Kind:          "redhat",
+						KernelVersion: "3.10.0-1160.80.1.el7.x86_64",
+						OldIDs:        []string{"a"},
+						NewIDs:        []string{"e"},
+						Removed:       []string{rhelPackage},
+						Added:         []string{rhelPackageMirror},
+					},
+				},
+			},
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			expected := test.expected
+			if expected.Added == nil {
+				expected.Added = []builderChange{}
+			}
+			if expected.Removed == nil {
+				expected.Removed = []builderChange{}
+			}
+			if expected.Images == nil {
+				expected.Images = []imageChange{}
+			}
+			if expected.Packages == nil {
+				expected.Packages = []packageChange{}
+			}
+
+			actual := diffManifests(test.oldManifest, test.newManifest)
+			assert.Equal(t, expected, actual)
+			assert.Equal(t, test.title == "same", actual.Empty())
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/stackrox/kernel-packer/tools/config/manifest"
+)
+
+func main() {
+	if err := mainCmd(); err != nil {
+		fmt.Fprintf(os.Stderr, "manifest-diff: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func mainCmd() error {
+	var (
+		flagFormat   = flag.String("format", "text", "Format of the differences. (one of text, or json)")
+		flagExitCode = flag.Bool("exit-code", false, "Exit with a non-zero status when the manifests differ.")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] old-manifest.yml new-manifest.yml\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		return errors.New("two manifests must be given")
+	}
+	if *flagFormat != "text" && *flagFormat != "json" {
+		return errors.Errorf("unknown format %q", *flagFormat)
+	}
+
+	oldManifest, err := manifest.Load(flag.Arg(0))
+	if err != nil {
+		return errors.Wrapf(err, "failed to load %s", flag.Arg(0))
+	}
+	newManifest, err := manifest.Load(flag.Arg(1))
+	if err != nil {
+		return errors.Wrapf(err, "failed to load %s", flag.Arg(1))
+	}
+
+	diff := diffManifests(oldManifest, newManifest)
+
+	if *flagFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(diff)
+	} else {
+		err = printText(os.Stdout, diff)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *flagExitCode && !diff.Empty() {
+		return errors.New("manifests differ")
+	}
+	return nil
+}
+
+// printText prints the given differences in a human-readable form. Added and
+// removed builders are grouped by kind, and by kernel version.
+func printText(w io.Writer, diff manifestDiff) error {
+	var p = &printer{w: w}
+
+	p.printf("%d added, %d removed, %d image changes, %d package changes\n",
+		len(diff.Added), len(diff.Removed), len(diff.Images), len(diff.Packages))
+
+	p.printBuilders("Added", diff.Added)
+	p.printBuilders("Removed", diff.Removed)
+
+	if len(diff.Images) > 0 {
+		p.printf("\nImage changes:\n")
+		for _, change := range diff.Images {
+			p.printf("  %s %s (%s): %s -> %s\n",
+				change.Kind, versionName(change.KernelVersion), change.ID,
+				imageName(change.OldImage), imageName(change.NewImage))
+		}
+	}
+
+	if len(diff.Packages) > 0 {
+		p.printf("\nPackage changes:\n")
+		for _, change := range diff.Packages {
+			p.printf("  %s %s (%s -> %s)\n",
+				change.Kind, kernelName(change.KernelVersion, change.Arch),
+				strings.Join(change.OldIDs, ", "), strings.Join(change.NewIDs, ", "))
+			for _, pkg := range change.Removed {
+				p.printf("    - %s\n", pkg)
+			}
+			for _, pkg := range change.Added {
+				p.printf("    + %s\n", pkg)
+			}
+		}
+	}
+
+	return p.err
+}
+
+// printer writes formatted output, and keeps the first error that occurs.
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+func (p *printer) printf(format string, args ...interface{}) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, args...)
+	}
+}
+
+func (p *printer) printBuilders(title string, changes []builderChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	p.printf("\n%s:\n", title)
+	for index, change := range changes {
+		if index == 0 || change.Kind != changes[index-1].Kind {
+			p.printf("  %s (%d)\n", change.Kind, countKind(changes, change.Kind))
+		}
+		if index == 0 || change.Kind != changes[index-1].Kind || change.KernelVersion != changes[index-1].KernelVersion ||
+			change.Arch != changes[index-1].Arch {
+			p.printf("    %s\n", kernelName(change.KernelVersion, change.Arch))
+		}
+		p.printf("      %s\n", change.ID)
+	}
+}
+
+func countKind(changes []builderChange, kind string) int {
+	var count int
+	for _, change := range changes {
+		if change.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// kernelName returns the name of the given kernel version, followed by the
+// given architecture, if any.
+func kernelName(version string, arch string) string {
+	if arch == "" {
+		return versionName(version)
+	}
+	return fmt.Sprintf("%s %s", versionName(version), arch)
+}
+
+func versionName(version string) string {
+	if version == "" {
+		return "(unknown version)"
+	}
+	return version
+}
+
+func imageName(image string) string {
+	if image == "" {
+		return "(default)"
+	}
+	return image
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/stackrox/kernel-packer/tools/config/manifest"
+	"github.com/stackrox/kernel-packer/tools/config/reformat"
+	"github.com/stackrox/kernel-packer/tools/util"
+)
+
+func main() {
+	if err := mainCmd(); err != nil {
+		fmt.Fprintf(os.Stderr, "manifest-query: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func mainCmd() error {
+	var (
+		flagManifest   = flag.String("manifest", "kernel-package-lists/manifest.yml", "Manifest file to select builders from.")
+		flagConfig     = flag.String("config", "kernel-package-lists/reformat.yml", "Config file containing the reformat entries, for -entry.")
+		flagID         = flag.String("id", "", "Select the builder whose id starts with the given prefix.")
+		flagKind       = flag.String("kind", "", "Select builders of the given kind, such as ubuntu.")
+		flagImage      = flag.String("image", "", "Select builders of the given packer image, or of the default one with \"default\".")
+		flagKernel     = flag.String("kernel", "", "Select builders whose kernel release, or any package, matches the given regular expression.")
+		flagMinVersion = flag.String("min-kernel-version", "", "Select builders of the given kernel version, such as 5.4.0, or later.")
+		flagMaxVersion = flag.String("max-kernel-version", "", "Select builders of the given kernel version, such as 5.4.0, or earlier.")
+		flagEntry      = flag.String("entry", "", "Select builders of packages of the given reformat entry.")
+		flagFormat     = flag.String("format", "manifest", "Format of the selected builders. (one of manifest, ids, or packages)")
+		flagOutput     = flag.String("output", "", "File to write the selected builders to, instead of stdout.")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 0 {
+		flag.Usage()
+		return errors.New("no arguments are accepted")
+	}
+	if *flagFormat != "manifest" && *flagFormat != "ids" && *flagFormat != "packages" {
+		return errors.Errorf("unknown format %q", *flagFormat)
+	}
+
+	q := query{
+		ID:               *flagID,
+		Kind:             *flagKind,
+		Image:            *flagImage,
+		MinKernelVersion: *flagMinVersion,
+		MaxKernelVersion: *flagMaxVersion,
+	}
+	if *flagKernel != "" {
+		regex, err := regexp.Compile(*flagKernel)
+		if err != nil {
+			return errors.Wrapf(err, "invalid pattern %q", *flagKernel)
+		}
+		q.Kernel = regex
+	}
+	if *flagEntry != "" {
+		entry, packages, err := entryPackages(*flagConfig, *flagEntry)
+		if err != nil {
+			return err
+		}
+		if q.Kind != "" && q.Kind != entry.Type {
+			return errors.Errorf("entry %q is of kind %s, not %s", entry.Name, entry.Type, q.Kind)
+		}
+		q.Kind = entry.Type
+		q.Packages = packages
+	}
+
+	mf, err := manifest.Load(*flagManifest)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load %s", *flagManifest)
+	}
+
+	filtered := q.Filter(mf)
+	if len(filtered) == 0 {
+		return errors.New("no builders match")
+	}
+
+	var w io.Writer = os.Stdout
+	if *flagOutput != "" {
+		file, err := os.Create(*flagOutput)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	switch *flagFormat {
+	case "ids":
+		err = printLines(w, filtered.SortedIDs())
+	case "packages":
+		err = printLines(w, sortedPackages(filtered))
+	default:
+		var body []byte
+		if body, err = yaml.Marshal(filtered); err == nil {
+			_, err = w.Write(body)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if *flagOutput != "" {
+		fmt.Fprintf(os.Stderr, "Selected %d builders into %s\n", len(filtered), *flagOutput)
+	}
+	return nil
+}
+
+// entryPackages returns the reformat entry of the given name, of the given
+// reformat config, and the set of its packages, as simplified names.
+func entryPackages(configFile string, name string) (reformat.Entry, map[string]struct{}, error) {
+	cfg, err := reformat.Load(configFile)
+	if err != nil {
+		return reformat.Entry{}, nil, err
+	}
+
+	for _, entry := range *cfg {
+		if entry.Name != name {
+			continue
+		}
+
+		body, err := ioutil.ReadFile(path.Join(path.Dir(configFile), entry.File))
+		if err != nil {
+			return reformat.Entry{}, nil, err
+		}
+
+		packages := make(map[string]struct{})
+		for _, line := range bytes.Split(body, []byte("\n")) {
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				packages[util.SimplifyURL(util.NormalizeURL(string(line)))] = struct{}{}
+			}
+		}
+		return entry, packages, nil
+	}
+
+	return reformat.Entry{}, nil, errors.Errorf("unknown entry %q", name)
+}
+
+// sortedPackages returns the packages of all builders of the given manifest,
+// without duplicates, sorted in alphabetical order.
+func sortedPackages(mf manifest.Manifest) []string {
+	var (
+		seen     = make(map[string]struct{})
+		packages []string
+	)
+	for _, builder := range mf {
+		for _, pkg := range builder.Packages {
+			if _, found := seen[pkg]; !found {
+				seen[pkg] = struct{}{}
+				packages = append(packages, pkg)
+			}
+		}
+	}
+	sort.Strings(packages)
+	return packages
+}
+
+func printLines(w io.Writer, lines []string) error {
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
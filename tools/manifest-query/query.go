@@ -0,0 +1,109 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/stackrox/kernel-packer/tools/config/manifest"
+	"github.com/stackrox/kernel-packer/tools/pkginfo"
+)
+
+// query selects builders of a manifest. Builders are selected when they match
+// every option that is given.
+type query struct {
+	// ID is a prefix of the ids of the builders to select.
+	ID string
+
+	// Kind is the kind of the builders to select, such as "ubuntu".
+	Kind string
+
+	// Image is the packer image of the builders to select. The default image
+	// is selected by "default".
+	Image string
+
+	// Kernel is a regular expression, that either the kernel release or one
+	// of the packages of a selected builder must match.
+	Kernel *regexp.Regexp
+
+	// MinKernelVersion and MaxKernelVersion limit the selected builders to
+	// the kernel versions in between, inclusive. Builders that have no
+	// kernel release are not selected by either.
+	MinKernelVersion string
+	MaxKernelVersion string
+
+	// Packages are the packages of a reformat entry, of which a selected
+	// builder must have at least one, if not nil.
+	Packages map[string]struct{}
+}
+
+// Match reports whether the given builder, of the given id, is selected by
+// the query.
+func (q *query) Match(id string, builder manifest.Builder) bool {
+	if q.ID != "" && !strings.HasPrefix(id, q.ID) {
+		return false
+	}
+	if q.Kind != "" && builder.Kind != q.Kind {
+		return false
+	}
+	if q.Image != "" && imageName(builder.Image) != q.Image {
+		return false
+	}
+	if q.Kernel != nil && !q.matchKernel(builder) {
+		return false
+	}
+	if q.MinKernelVersion != "" || q.MaxKernelVersion != "" {
+		if builder.Kernel == "" {
+			return false
+		}
+		if q.MinKernelVersion != "" && pkginfo.VersionLess(builder.Kernel, q.MinKernelVersion) {
+			return false
+		}
+		if q.MaxKernelVersion != "" && pkginfo.VersionLess(q.MaxKernelVersion, builder.Kernel) {
+			return false
+		}
+	}
+	if q.Packages != nil && !q.matchPackages(builder) {
+		return false
+	}
+	return true
+}
+
+func (q *query) matchKernel(builder manifest.Builder) bool {
+	if builder.Kernel != "" && q.Kernel.MatchString(builder.Kernel) {
+		return true
+	}
+	for _, pkg := range builder.Packages {
+		if q.Kernel.MatchString(pkg) {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *query) matchPackages(builder manifest.Builder) bool {
+	for _, pkg := range builder.Packages {
+		if _, found := q.Packages[pkg]; found {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns a manifest of the builders of the given manifest that are
+// selected by the query.
+func (q *query) Filter(mf manifest.Manifest) manifest.Manifest {
+	filtered := manifest.New()
+	for id, builder := range mf {
+		if q.Match(id, builder) {
+			filtered[id] = builder
+		}
+	}
+	return filtered
+}
+
+func imageName(image string) string {
+	if image == "" {
+		return "default"
+	}
+	return image
+}
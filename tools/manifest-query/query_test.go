@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stackrox/kernel-packer/tools/config/manifest"
+)
+
+const (
+	rhelPackage   = "https---mirrors.kernel.org-centos-7.9.2009-updates-x86_64-Packages-kernel-devel-3.10.0-1160.80.1.el7.x86_64.rpm"
+	ubuntuPackage = "http---security.ubuntu.com-ubuntu-pool-main-l-linux-gcp-5.8-linux-headers-5.8.0-1036-gcp_5.8.0-1036.38-20.04.1_amd64.deb"
+	ubuntuCommon  = "http---security.ubuntu.com-ubuntu-pool-main-l-linux-gcp-5.8-linux-gcp-5.8-headers-5.8.0-1036_5.8.0-1036.38-20.04.1_amd64.deb"
+	cosPackage    = "https---storage.googleapis.com-cos-tools-12739.68.0-kernel-headers.tgz"
+)
+
+func TestQueryFilter(t *testing.T) {
+	mf := manifest.Manifest{
+		"1111": {
+			Kind:     "redhat",
+			Packages: []string{rhelPackage},
+			Kernel:   "3.10.0-1160.80.1.el7.x86_64",
+		},
+		"2222": {
+			Kind:     "ubuntu",
+			Packages: []string{ubuntuCommon, ubuntuPackage},
+			Image:    "ubuntu-20.04",
+			Kernel:   "5.8.0-1036-gcp",
+			Flavour:  "gcp",
+		},
+		"3333": {
+			Kind:     "cos",
+			Packages: []string{cosPackage},
+		},
+	}
+
+	tests := []struct {
+		title    string
+		query    query
+		expected []string
+	}{
+		{
+			title:    "everything",
+			expected: []string{"1111", "2222", "3333"},
+		},
+		{
+			title:    "id prefix",
+			query:    query{ID: "22"},
+			expected: []string{"2222"},
+		},
+		{
+			title:    "kind",
+			query:    query{Kind: "cos"},
+			expected: []string{"3333"},
+		},
+		{
+			title:    "image",
+			query:    query{Image: "ubuntu-20.04"},
+			expected: []string{"2222"},
+		},
+		{
+			title:    "default image",
+			query:    query{Image: "default"},
+			expected: []string{"1111", "3333"},
+		},
+		{
+			title:    "kernel release",
+			query:    query{Kernel: regexp.MustCompile(`-gcp$`)},
+			expected: []string{"2222"},
+		},
+		{
+			title:    "kernel package",
+			query:    query{Kernel: regexp.MustCompile(`12739\.68`)},
+			expected: []string{"3333"},
+		},
+		{
+			title:    "min kernel version",
+			query:    query{MinKernelVersion: "4.0.0"},
+			expected: []string{"2222"},
+		},
+		{
+			title:    "max kernel version",
+			query:    query{MaxKernelVersion: "5.8.0"},
+			expected: []string{"1111", "2222"},
+		},
+		{
+			title:    "kernel version range",
+			query:    query{MinKernelVersion: "3.10.0", MaxKernelVersion: "3.10.0"},
+			expected: []string{"1111"},
+		},
+		{
+			title:    "entry packages",
+			query:    query{Packages: map[string]struct{}{ubuntuCommon: {}}},
+			expected: []string{"2222"},
+		},
+		{
+			title:    "every option",
+			query:    query{Kind: "ubuntu", Kernel: regexp.MustCompile(`gcp`), MaxKernelVersion: "5.4.0"},
+			expected: []string{},
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.query.Filter(mf).SortedIDs())
+		})
+	}
+}
+
+func TestSortedPackages(t *testing.T) {
+	mf := manifest.Manifest{
+		"1111": {Packages: []string{ubuntuPackage, ubuntuCommon}},
+		"2222": {Packages: []string{ubuntuCommon, rhelPackage}},
+	}
+
+	assert.Equal(t, []string{ubuntuCommon, ubuntuPackage, rhelPackage}, sortedPackages(mf))
+}
+
+func TestEntryPackages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest-query")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	config := "- name: centos\n  type: redhat\n  reformat: single\n  file: centos.txt\n"
+	list := "https://mirrors.kernel.org/centos/7.9.2009/updates/x86_64/Packages/kernel-devel-3.10.0-1160.80.1.el7.x86_64.rpm\n" +
+		"centos-7 https://mirrors.kernel.org/centos/7.9.2009/updates/x86_64/Packages/kernel-devel-3.10.0-1160.80.1.el7.x86_64.rpm\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "reformat.yml"), []byte(config), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "centos.txt"), []byte(list), 0644))
+
+	entry, packages, err := entryPackages(filepath.Join(dir, "reformat.yml"), "centos")
+	require.NoError(t, err)
+	assert.Equal(t, "redhat", entry.Type)
+	assert.Equal(t, map[string]struct{}{rhelPackage: {}}, packages)
+
+	_, _, err = entryPackages(filepath.Join(dir, "reformat.yml"), "ubuntu")
+	assert.EqualError(t, err, `unknown entry "ubuntu"`)
+}
@@ -0,0 +1,237 @@
+package packer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	functionStartRegex = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)\(\) *\{`)
+	includesRegex      = regexp.MustCompile(`/etc/includes/([A-Za-z0-9_.-]+)`)
+
+	// wordRegex matches every whole word, so that a function is found to be
+	// called by its name appearing as a word of its own.
+	wordRegex = regexp.MustCompile(`\w+`)
+
+	// dispatchRegex matches the case statement of the main function that
+	// dispatches to the repackage function of every kind.
+	dispatchRegex = regexp.MustCompile(`(?ms)^\s*case "\$distro" in$.*?^\s*esac$\n?`)
+)
+
+// Fingerprinter computes fingerprints of the packer sources that are used to
+// build bundles of a given kind with a given image. Fingerprints are computed
+// from the sources rather than from image digests, as the images are rebuilt
+// on every build host. A Fingerprinter is safe for concurrent use.
+type Fingerprinter struct {
+	dir       string
+	functions map[string]string
+	global    string
+
+	mutex sync.Mutex
+	cache map[string]string
+}
+
+// NewFingerprinter reads the packer entrypoint script from the given packer
+// directory (typically "packers").
+func NewFingerprinter(dir string) (*Fingerprinter, error) {
+	body, err := ioutil.ReadFile(filepath.Join(dir, "entrypoint"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read packer entrypoint")
+	}
+
+	// Only the dispatch of the main function is left out, so that changes to
+	// the rest of it, such as how the metadata of a build is handled, are
+	// part of the fingerprint of every kind.
+	functions, global := splitFunctions(body)
+	if main, found := functions["main"]; found {
+		functions["main"] = dispatchRegex.ReplaceAllString(main, "")
+	}
+	return &Fingerprinter{
+		dir:       dir,
+		functions: functions,
+		global:    global,
+		cache:     make(map[string]string),
+	}, nil
+}
+
+// Fingerprint returns a checksum of everything that goes into building a
+// bundle of the given kind with the given image: the main and repackage_<kind>
+// functions in the entrypoint script along with every function that they
+// call, the Dockerfile for the image, and any files that are copied into the
+// image from packers/includes and used by the kind. The case statement of the
+// main function is left out, as it dispatches to every kind, so that a change
+// that only concerns one kind does not invalidate the bundles of every other
+// kind.
+func (f *Fingerprinter) Fingerprint(kind string, image string) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	key := kind + "\x00" + image
+	if fingerprint, found := f.cache[key]; found {
+		return fingerprint, nil
+	}
+
+	entry := "repackage_" + kind
+	if _, found := f.functions[entry]; !found {
+		return "", errors.Errorf("packer entrypoint has no %s function", entry)
+	}
+
+	var (
+		s     = sha256.New()
+		names = f.calledFunctions("main", entry)
+	)
+
+	fmt.Fprintf(s, "global\x00%s\x00", f.global)
+	for _, name := range names {
+		fmt.Fprintf(s, "function\x00%s\x00%s\x00", name, f.functions[name])
+	}
+
+	dockerfile := DockerfileName(image)
+	body, err := ioutil.ReadFile(filepath.Join(f.dir, dockerfile))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read Dockerfile for image %q", image)
+	}
+	fmt.Fprintf(s, "dockerfile\x00%s\x00%s\x00", dockerfile, body)
+
+	for _, name := range names {
+		for _, match := range includesRegex.FindAllStringSubmatch(f.functions[name], -1) {
+			if err := hashDir(s, filepath.Join(f.dir, "includes", match[1])); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	fingerprint := fmt.Sprintf("%x", s.Sum(nil))
+	f.cache[key] = fingerprint
+	return fingerprint, nil
+}
+
+// DockerfileName returns the name of the Dockerfile, inside of the packer
+// directory, that the given image is built from. The default "repackage"
+// image is built from Dockerfile, and any "repackage-<name>" image from
+// Dockerfile.<name>.
+func DockerfileName(image string) string {
+	if index := strings.LastIndex(image, ":"); index >= 0 {
+		image = image[:index]
+	}
+	if suffix := strings.TrimPrefix(image, "repackage-"); image != "" && suffix != image {
+		return "Dockerfile." + suffix
+	}
+	return "Dockerfile"
+}
+
+// calledFunctions returns the given functions, and every function that they
+// call, directly or indirectly, in alphabetical order.
+func (f *Fingerprinter) calledFunctions(roots ...string) []string {
+	var (
+		seen  = make(map[string]struct{})
+		queue = append([]string{}, roots...)
+	)
+
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if _, found := seen[name]; found {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		for _, word := range wordRegex.FindAllString(f.functions[name], -1) {
+			if _, found := f.functions[word]; found && word != name {
+				queue = append(queue, word)
+			}
+		}
+	}
+
+	var names []string
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// splitFunctions splits the given shell script into its top-level functions,
+// keyed by name, and the remaining top-level code. Top-level comments and
+// blank lines are dropped.
+func splitFunctions(script []byte) (map[string]string, string) {
+	var (
+		functions = make(map[string]string)
+		global    strings.Builder
+		current   string
+		body      strings.Builder
+		scanner   = bufio.NewScanner(bytes.NewReader(script))
+	)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if current != "" {
+			body.WriteString(line)
+			body.WriteString("\n")
+			if line == "}" {
+				functions[current] = body.String()
+				current = ""
+				body.Reset()
+			}
+			continue
+		}
+
+		if matches := functionStartRegex.FindStringSubmatch(line); matches != nil {
+			current = matches[1]
+			body.WriteString(line)
+			body.WriteString("\n")
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		global.WriteString(line)
+		global.WriteString("\n")
+	}
+
+	return functions, global.String()
+}
+
+// hashDir writes the names and contents of every file inside of the given
+// directory into the given writer, in a consistent order.
+func hashDir(w io.Writer, dir string) error {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to read packer includes %s", dir)
+	}
+
+	sort.Strings(files)
+	for _, file := range files {
+		body, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, file)
+		fmt.Fprintf(w, "include\x00%s\x00%s\x00", rel, body)
+	}
+	return nil
+}
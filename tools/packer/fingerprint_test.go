@@ -0,0 +1,180 @@
+package packer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testEntrypoint = `#!/usr/bin/env bash
+set -euo pipefail
+
+main() {
+    local distro="$2"
+    metadata="$3/bundle.json"
+
+    case "$distro" in
+        redhat)
+            repackage_redhat "$@"
+        ;;
+        ubuntu)
+            repackage_ubuntu "$@"
+        ;;
+        cos)
+            repackage_cos "$@"
+        ;;
+    esac
+}
+
+# Repackages a RedHat RPM.
+repackage_redhat() {
+    log 'redhat'
+    bundle_meta
+}
+
+repackage_ubuntu() {
+    extract_deb
+    bundle_meta
+}
+
+repackage_cos() {
+    cp /etc/includes/cos/.config .
+}
+
+bundle_meta() {
+    log 'meta'
+}
+
+extract_deb() {
+    dpkg -x
+}
+
+log() {
+    printf '%s\n' "$*" 1>&2
+}
+
+main "$@"
+`
+
+func TestDockerfileName(t *testing.T) {
+	assert.Equal(t, "Dockerfile", DockerfileName(""))
+	assert.Equal(t, "Dockerfile", DockerfileName("repackage"))
+	assert.Equal(t, "Dockerfile", DockerfileName("repackage:latest"))
+	assert.Equal(t, "Dockerfile.bookworm", DockerfileName("repackage-bookworm"))
+	assert.Equal(t, "Dockerfile.bookworm", DockerfileName("repackage-bookworm:latest"))
+}
+
+func TestFingerprint(t *testing.T) {
+	files := map[string]string{
+		"entrypoint":           testEntrypoint,
+		"Dockerfile":           "FROM debian:buster\n",
+		"Dockerfile.bookworm":  "FROM debian:bookworm\n",
+		"includes/cos/.config": "CONFIG_A=y\n",
+	}
+
+	fingerprints := func(t *testing.T, changes map[string]string) map[string]string {
+		dir, err := ioutil.TempDir("", "packer")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		for name, body := range files {
+			if changed, found := changes[name]; found {
+				body = changed
+			}
+			path := filepath.Join(dir, name)
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+			require.NoError(t, ioutil.WriteFile(path, []byte(body), 0644))
+		}
+
+		f, err := NewFingerprinter(dir)
+		require.NoError(t, err)
+
+		results := make(map[string]string)
+		for _, kind := range []string{"redhat", "ubuntu", "cos"} {
+			for _, image := range []string{"", "repackage-bookworm"} {
+				fingerprint, err := f.Fingerprint(kind, image)
+				require.NoError(t, err)
+				results[fmt.Sprintf("%s %s", kind, image)] = fingerprint
+			}
+		}
+		return results
+	}
+
+	baseline := fingerprints(t, nil)
+	assert.NotEqual(t, baseline["redhat "], baseline["ubuntu "])
+	assert.NotEqual(t, baseline["ubuntu "], baseline["ubuntu repackage-bookworm"])
+
+	tests := []struct {
+		title   string
+		changes map[string]string
+		changed []string
+	}{
+		{
+			title:   "comment",
+			changes: map[string]string{"entrypoint": strings.Replace(testEntrypoint, "Repackages a RedHat RPM", "Repackages RPMs", 1)},
+		},
+		{
+			title:   "kind function",
+			changes: map[string]string{"entrypoint": strings.Replace(testEntrypoint, "dpkg -x", "dpkg -x -v", 1)},
+			changed: []string{"ubuntu ", "ubuntu repackage-bookworm"},
+		},
+		{
+			title:   "shared function",
+			changes: map[string]string{"entrypoint": strings.Replace(testEntrypoint, "log 'meta'", "log 'bundle'", 1)},
+			changed: []string{"redhat ", "redhat repackage-bookworm", "ubuntu ", "ubuntu repackage-bookworm"},
+		},
+		{
+			title:   "main function",
+			changes: map[string]string{"entrypoint": strings.Replace(testEntrypoint, "/bundle.json", "/metadata.json", 1)},
+			changed: []string{"redhat ", "redhat repackage-bookworm", "ubuntu ", "ubuntu repackage-bookworm", "cos ", "cos repackage-bookworm"},
+		},
+		{
+			title:   "dispatch",
+			changes: map[string]string{"entrypoint": strings.Replace(testEntrypoint, `repackage_cos "$@"`, "log 'cos'\n            repackage_cos \"$@\"", 1)},
+		},
+		{
+			title:   "dockerfile",
+			changes: map[string]string{"Dockerfile.bookworm": "FROM debian:trixie\n"},
+			changed: []string{"redhat repackage-bookworm", "ubuntu repackage-bookworm", "cos repackage-bookworm"},
+		},
+		{
+			title:   "includes",
+			changes: map[string]string{"includes/cos/.config": "CONFIG_A=n\n"},
+			changed: []string{"cos ", "cos repackage-bookworm"},
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			actual := fingerprints(t, test.changes)
+
+			var changed []string
+			for key, fingerprint := range actual {
+				if baseline[key] != fingerprint {
+					changed = append(changed, key)
+				}
+			}
+			assert.ElementsMatch(t, test.changed, changed)
+		})
+	}
+}
+
+func TestFingerprintUnknownKind(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "entrypoint"), []byte(testEntrypoint), 0644))
+
+	f, err := NewFingerprinter(dir)
+	require.NoError(t, err)
+
+	_, err = f.Fingerprint("gentoo", "")
+	assert.EqualError(t, err, "packer entrypoint has no repackage_gentoo function")
+}
@@ -0,0 +1,356 @@
+package pkginfo
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/stackrox/kernel-packer/tools/util"
+)
+
+// Format is the file format of a kernel package.
+type Format string
+
+const (
+	FormatRPM            Format = "rpm"
+	FormatDeb            Format = "deb"
+	FormatTarball        Format = "tarball"
+	FormatContainerImage Format = "container-image"
+	FormatConfig         Format = "config"
+)
+
+// Info is what the URL of a crawled kernel package tells about it. Fields that
+// the package doesn't name are empty.
+type Info struct {
+	// Family is the distribution that the package is of, such as "ubuntu",
+	// "debian", "rhel", "amazon", "oracle" or "cos". CentOS packages are of
+	// the "rhel" family. It is empty for upstream kernel sources.
+	Family string
+
+	// Name is the package name, such as "linux-headers-5.4.0-1048-gke" or
+	// "kernel-devel".
+	Name string
+
+	// Flavour is the kernel flavour that the package is built for, such as
+	// "generic", "aws", "gke", "cloud-amd64", "rt", "uek" or "default". It is
+	// empty for packages that are shared by every flavour of a kernel, such
+	// as the Debian common headers.
+	Flavour string
+
+	// KernelVersion is the upstream kernel version, such as "5.4.0".
+	KernelVersion string
+
+	// KernelRelease is the kernel release named by the package, without the
+	// flavour and architecture, such as "5.4.0-1048", "6.1.0-21" or
+	// "4.18.0-305.el8". It is the kernel series of Debian kbuild packages,
+	// such as "6.1".
+	KernelRelease string
+
+	// ABI is the kernel ABI named by Debian and Ubuntu packages, such as
+	// "1048".
+	ABI string
+
+	// Version is the full version of the package, such as
+	// "5.4.0-1048.50~18.04.1" or "4.18.0-305.el8".
+	Version string
+
+	// Revision is the distribution revision of the package, such as "50" for
+	// Ubuntu, "1" for Debian, or "305.el8" for RPMs. It is the OS release of
+	// COS, CoreOS and Flatcar packages, and the minikube version of minikube
+	// configs.
+	Revision string
+
+	// Backport is the backport suffix of the package version, such as
+	// "~18.04.1" or "~bpo8+1".
+	Backport string
+
+	// Arch is the kernel architecture of the package, such as "x86_64". It is
+	// empty for architecture independent packages.
+	Arch string
+
+	// Format is the file format of the package.
+	Format Format
+}
+
+var (
+	// debFilenameRegex matches the name, version and architecture of a Debian
+	// package file, such as "linux-kbuild-6.1_6.1.90-1_amd64.deb".
+	debFilenameRegex = regexp.MustCompile(`^([a-z0-9][a-z0-9.+-]*)_([^_]+)_([a-z0-9]+)\.deb$`)
+
+	// debKBuildRegex matches Debian kbuild package names, such as
+	// "linux-kbuild-6.1".
+	debKBuildRegex = regexp.MustCompile(`^linux-kbuild-(\d+(?:\.\d+)*)$`)
+
+	// debHeadersRegex matches headers package names, that are named after
+	// the kernel release, such as "linux-headers-5.4.0-1048-gke",
+	// "linux-headers-6.1.0-21-cloud-amd64" or "linux-headers-4.4.102-k8s".
+	debHeadersRegex = regexp.MustCompile(`^linux-headers-(\d+\.\d+(?:\.\d+)?)(?:-(\d+|gardenlinux|garden))?(?:-([a-z][a-z0-9]*(?:-[a-z0-9]+)*))?$`)
+
+	// debSourceHeadersRegex matches Ubuntu headers package names, that are
+	// named after the kernel source package, such as
+	// "linux-gcp-5.4-headers-5.4.0-1042" or "linux-aws-headers-5.4.0-1048".
+	debSourceHeadersRegex = regexp.MustCompile(`^linux-([a-z]+(?:-[a-z]+)*)(?:-\d+\.\d+)?-headers-(\d+\.\d+\.\d+)-(\d+)$`)
+
+	// rpmFilenameRegex matches the name, version, release and architecture of
+	// an RPM package file, such as "kernel-devel-4.18.0-305.el8.x86_64.rpm".
+	rpmFilenameRegex = regexp.MustCompile(`^(.+)-([^-]+)-([^-]+)\.([^.]+)\.rpm$`)
+
+	// rpmFlavourRegex matches the flavour of RPM package names, such as
+	// "kernel-uek-devel".
+	rpmFlavourRegex = regexp.MustCompile(`^kernel-([a-z0-9]+)-devel$`)
+
+	// rpmFamilies maps the dist tags of RPM releases to distributions, in
+	// order of precedence.
+	rpmFamilies = []struct {
+		regex  *regexp.Regexp
+		family string
+	}{
+		{regexp.MustCompile(`\.el\d+uek$`), "oracle"},
+		{regexp.MustCompile(`\.amzn\d+`), "amazon"},
+		{regexp.MustCompile(`\.fc\d+`), "fedora"},
+		{regexp.MustCompile(`\.el\d+`), "rhel"},
+	}
+
+	// The following match the whole package URL, or simplified package name,
+	// since the directories of these packages name their versions.
+	cosRegex                = regexp.MustCompile(`cos-tools[-/](\d+(?:\.\d+)*)[-/](kernel-[a-z]+)\.(?:tar\.gz|tgz)$`)
+	developerContainerRegex = regexp.MustCompile(`(amd64|arm64)-usr[-/](\d+(?:\.\d+)*)[-/]([a-z]+)_developer_container\.bin\.bz2$`)
+	minikubeRegex           = regexp.MustCompile(`minikube[-/]v(\d+\.\d+\.\d+)[-/].*[-/]([a-z0-9_]*defconfig)[-?]kernel[-=](\d+\.\d+\.\d+)$`)
+	linuxkitRegex           = regexp.MustCompile(`linuxkit[-/]linux[-/]archive[-/]v(\d+\.\d+(?:\.\d+)?)\.tar\.gz$`)
+	upstreamRegex           = regexp.MustCompile(`(?:^|[-/])linux-(\d+\.\d+(?:\.\d+)?)\.tar\.(?:gz|xz)$`)
+)
+
+// Parse returns what the given package URL, or simplified package name, tells
+// about the package. An error is returned if it isn't a recognized kernel
+// package. Simplified package names have the "~" and "+" of Debian versions
+// replaced, so their backport suffix is left in the Revision.
+func Parse(pkg string) (Info, error) {
+	switch name := fileName(pkg); {
+	case strings.HasSuffix(name, ".deb"):
+		if info, ok := parseDeb(pkg, name); ok {
+			return info, nil
+		}
+	case strings.HasSuffix(name, ".rpm"):
+		if info, ok := parseRPM(pkg, name); ok {
+			return info, nil
+		}
+	default:
+		if info, ok := parseOther(pkg); ok {
+			return info, nil
+		}
+	}
+	return Info{}, errors.Errorf("unrecognized package %s", pkg)
+}
+
+// PackagesKernelVersion returns the kernel version of the first of the given
+// packages that names one, or an empty string if none do.
+func PackagesKernelVersion(packages []string) string {
+	for _, pkg := range packages {
+		if info, err := Parse(pkg); err == nil && info.KernelVersion != "" {
+			return info.KernelVersion
+		}
+	}
+	return ""
+}
+
+// fileName returns the file name of the given package URL. Simplified package
+// names have no directories, so their file name is taken to start at the last
+// "linux-" or "kernel-" that follows a separator.
+func fileName(pkg string) string {
+	if index := strings.LastIndex(pkg, "/"); index >= 0 {
+		return pkg[index+1:]
+	}
+
+	start := 0
+	for index := 1; index < len(pkg); index++ {
+		if pkg[index-1] == '-' && (strings.HasPrefix(pkg[index:], "linux-") || strings.HasPrefix(pkg[index:], "kernel-")) {
+			start = index
+		}
+	}
+	return pkg[start:]
+}
+
+func parseDeb(pkg string, name string) (Info, bool) {
+	matches := debFilenameRegex.FindStringSubmatch(name)
+	if matches == nil {
+		return Info{}, false
+	}
+
+	info := Info{
+		Name:    matches[1],
+		Version: matches[2],
+		Arch:    util.PackageArch(name),
+		Format:  FormatDeb,
+	}
+
+	// Versions are [epoch:]upstream[-revision], and backports are suffixed
+	// to the revision, such as "4.4-4~bpo8+1". Kernel upstream versions have
+	// no hyphens, unlike the revisions of simplified package names.
+	version := info.Version
+	if index := strings.Index(version, ":"); index >= 0 {
+		version = version[index+1:]
+	}
+	info.KernelVersion = version
+	if index := strings.Index(version, "-"); index >= 0 {
+		info.KernelVersion, info.Revision = version[:index], version[index+1:]
+	}
+	if index := strings.Index(info.Revision, "~"); index >= 0 {
+		info.Revision, info.Backport = info.Revision[:index], info.Revision[index:]
+	}
+
+	if matches := debKBuildRegex.FindStringSubmatch(info.Name); matches != nil {
+		info.KernelRelease = matches[1]
+	} else if matches := debHeadersRegex.FindStringSubmatch(info.Name); matches != nil {
+		info.KernelRelease = matches[1]
+		info.ABI = matches[2]
+		info.Flavour = matches[3]
+		if info.ABI != "" {
+			info.KernelRelease += "-" + info.ABI
+		}
+		if info.Flavour == "common" {
+			info.Flavour = ""
+		}
+	} else if matches := debSourceHeadersRegex.FindStringSubmatch(info.Name); matches != nil {
+		info.Flavour = matches[1]
+		info.KernelRelease = matches[2] + "-" + matches[3]
+		info.ABI = matches[3]
+	}
+
+	// Ubuntu revisions start with the ABI, such as "1048.50".
+	if info.ABI != "" && strings.HasPrefix(info.Revision, info.ABI+".") {
+		info.Revision = strings.TrimPrefix(info.Revision, info.ABI+".")
+	}
+
+	switch {
+	case strings.Contains(pkg, "ubuntu.com"):
+		info.Family = "ubuntu"
+	case strings.HasPrefix(info.ABI, "garden") || strings.Contains(info.Revision, "gardenlinux"):
+		info.Family = "gardenlinux"
+	default:
+		info.Family = "debian"
+	}
+	return info, true
+}
+
+func parseRPM(pkg string, name string) (Info, bool) {
+	matches := rpmFilenameRegex.FindStringSubmatch(name)
+	if matches == nil {
+		return Info{}, false
+	}
+
+	info := Info{
+		Name:          matches[1],
+		KernelVersion: matches[2],
+		KernelRelease: matches[2] + "-" + matches[3],
+		Version:       matches[2] + "-" + matches[3],
+		Revision:      matches[3],
+		Arch:          util.PackageArch(name),
+		Format:        FormatRPM,
+	}
+	if matches := rpmFlavourRegex.FindStringSubmatch(info.Name); matches != nil {
+		info.Flavour = matches[1]
+	}
+
+	for _, family := range rpmFamilies {
+		if family.regex.MatchString(info.Revision) {
+			info.Family = family.family
+			break
+		}
+	}
+	if info.Family == "" && strings.Contains(pkg, "suse") {
+		info.Family = "suse"
+	}
+	return info, true
+}
+
+// parseOther parses the packages that are not Debian or RPM packages, such as
+// kernel sources and configs, and developer container images.
+func parseOther(pkg string) (Info, bool) {
+	if matches := cosRegex.FindStringSubmatch(pkg); matches != nil {
+		return Info{
+			Family:   "cos",
+			Name:     matches[2],
+			Revision: matches[1],
+			Format:   FormatTarball,
+		}, true
+	}
+
+	if matches := developerContainerRegex.FindStringSubmatch(pkg); matches != nil {
+		arch, _ := util.NormalizeArch(matches[1])
+		return Info{
+			Family:   matches[3],
+			Name:     matches[3] + "_developer_container",
+			Revision: matches[2],
+			Arch:     arch,
+			Format:   FormatContainerImage,
+		}, true
+	}
+
+	if matches := minikubeRegex.FindStringSubmatch(pkg); matches != nil {
+		return Info{
+			Family:        "minikube",
+			Name:          matches[2],
+			KernelVersion: matches[3],
+			KernelRelease: matches[3],
+			Revision:      matches[1],
+			Format:        FormatConfig,
+		}, true
+	}
+
+	for _, source := range []struct {
+		regex  *regexp.Regexp
+		family string
+	}{
+		{linuxkitRegex, "linuxkit"},
+		{upstreamRegex, ""},
+	} {
+		if matches := source.regex.FindStringSubmatch(pkg); matches != nil {
+			return Info{
+				Family:        source.family,
+				Name:          "linux",
+				KernelVersion: matches[1],
+				KernelRelease: matches[1],
+				Format:        FormatTarball,
+			}, true
+		}
+	}
+	return Info{}, false
+}
+
+// Uname returns the kernel release, as in "uname -r", of the kernel that the
+// package is built for, such as "5.4.0-1048-gke" or "4.18.0-305.el8.x86_64".
+// It is empty if the package doesn't name it, such as the headers that are
+// shared by every flavour of a kernel, or COS and Flatcar packages.
+func (i Info) Uname() string {
+	switch i.Format {
+	case FormatDeb:
+		// Flavour specific headers packages are named after the kernel
+		// release.
+		if i.Flavour != "" && strings.HasPrefix(i.Name, "linux-headers-") {
+			return strings.TrimPrefix(i.Name, "linux-headers-")
+		}
+	case FormatRPM:
+		if i.Arch == "" {
+			return ""
+		}
+		if i.Family == "suse" {
+			// SUSE kernel releases leave out the build number of the
+			// package release, and name the flavour instead of the
+			// architecture, such as "4.12.14-95.93-default".
+			if i.Flavour == "" {
+				return ""
+			}
+			release := i.KernelRelease
+			if index := strings.LastIndex(release, "."); index > strings.Index(release, "-") {
+				release = release[:index]
+			}
+			return release + "-" + i.Flavour
+		}
+		return i.KernelRelease + "." + i.Arch
+	case FormatTarball, FormatConfig:
+		// Kernels that are built from sources have the upstream version.
+		return i.KernelVersion
+	}
+	return ""
+}
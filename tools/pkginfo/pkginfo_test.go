@@ -0,0 +1,403 @@
+package pkginfo
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stackrox/kernel-packer/tools/util"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		title    string
+		url      string
+		expected Info
+		err      string
+	}{
+		{
+			title: "ubuntu headers",
+			url:   "http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-headers-5.4.0-1048-gke_5.4.0-1048.50_amd64.deb",
+			expected: Info{
+				Family:        "ubuntu",
+				Name:          "linux-headers-5.4.0-1048-gke",
+				Flavour:       "gke",
+				KernelVersion: "5.4.0",
+				KernelRelease: "5.4.0-1048",
+				ABI:           "1048",
+				Version:       "5.4.0-1048.50",
+				Revision:      "50",
+				Arch:          "x86_64",
+				Format:        FormatDeb,
+			},
+		},
+		{
+			title: "ubuntu backport source headers",
+			url:   "http://security.ubuntu.com/ubuntu/pool/main/l/linux-gcp-5.4/linux-gcp-5.4-headers-5.4.0-1084_5.4.0-1084.92~18.04.1_all.deb",
+			expected: Info{
+				Family:        "ubuntu",
+				Name:          "linux-gcp-5.4-headers-5.4.0-1084",
+				Flavour:       "gcp",
+				KernelVersion: "5.4.0",
+				KernelRelease: "5.4.0-1084",
+				ABI:           "1084",
+				Version:       "5.4.0-1084.92~18.04.1",
+				Revision:      "92",
+				Backport:      "~18.04.1",
+				Format:        FormatDeb,
+			},
+		},
+		{
+			title: "ubuntu generic common headers",
+			url:   "http://security.ubuntu.com/ubuntu/pool/main/l/linux/linux-headers-5.4.0-124_5.4.0-124.140_all.deb",
+			expected: Info{
+				Family:        "ubuntu",
+				Name:          "linux-headers-5.4.0-124",
+				KernelVersion: "5.4.0",
+				KernelRelease: "5.4.0-124",
+				ABI:           "124",
+				Version:       "5.4.0-124.140",
+				Revision:      "140",
+				Format:        FormatDeb,
+			},
+		},
+		{
+			title: "debian headers",
+			url:   "http://security.debian.org/pool/updates/main/l/linux/linux-headers-6.1.0-21-cloud-amd64_6.1.90-1_amd64.deb",
+			expected: Info{
+				Family:        "debian",
+				Name:          "linux-headers-6.1.0-21-cloud-amd64",
+				Flavour:       "cloud-amd64",
+				KernelVersion: "6.1.90",
+				KernelRelease: "6.1.0-21",
+				ABI:           "21",
+				Version:       "6.1.90-1",
+				Revision:      "1",
+				Arch:          "x86_64",
+				Format:        FormatDeb,
+			},
+		},
+		{
+			title: "debian common headers",
+			url:   "http://security.debian.org/pool/updates/main/l/linux/linux-headers-6.1.0-21-common_6.1.90-1_all.deb",
+			expected: Info{
+				Family:        "debian",
+				Name:          "linux-headers-6.1.0-21-common",
+				KernelVersion: "6.1.90",
+				KernelRelease: "6.1.0-21",
+				ABI:           "21",
+				Version:       "6.1.90-1",
+				Revision:      "1",
+				Format:        FormatDeb,
+			},
+		},
+		{
+			title: "debian backport kbuild",
+			url:   "http://http.us.debian.org/debian/pool/main/l/linux-tools/linux-kbuild-4.4_4.4-4~bpo8+1_amd64.deb",
+			expected: Info{
+				Family:        "debian",
+				Name:          "linux-kbuild-4.4",
+				KernelVersion: "4.4",
+				KernelRelease: "4.4",
+				Version:       "4.4-4~bpo8+1",
+				Revision:      "4",
+				Backport:      "~bpo8+1",
+				Arch:          "x86_64",
+				Format:        FormatDeb,
+			},
+		},
+		{
+			title: "garden linux headers",
+			url:   "http://repo.gardenlinux.io/gardenlinux/pool/main/l/linux-5.15/linux-headers-5.15.114-gardenlinux-cloud-amd64_5.15.114-0gardenlinux3_amd64.deb",
+			expected: Info{
+				Family:        "gardenlinux",
+				Name:          "linux-headers-5.15.114-gardenlinux-cloud-amd64",
+				Flavour:       "cloud-amd64",
+				KernelVersion: "5.15.114",
+				KernelRelease: "5.15.114-gardenlinux",
+				ABI:           "gardenlinux",
+				Version:       "5.15.114-0gardenlinux3",
+				Revision:      "0gardenlinux3",
+				Arch:          "x86_64",
+				Format:        FormatDeb,
+			},
+		},
+		{
+			title: "rhel",
+			url:   "https://cdn.redhat.com/content/dist/rhel8/8/x86_64/baseos/os/Packages/k/kernel-devel-4.18.0-147.0.2.el8_1.x86_64.rpm",
+			expected: Info{
+				Family:        "rhel",
+				Name:          "kernel-devel",
+				KernelVersion: "4.18.0",
+				KernelRelease: "4.18.0-147.0.2.el8_1",
+				Version:       "4.18.0-147.0.2.el8_1",
+				Revision:      "147.0.2.el8_1",
+				Arch:          "x86_64",
+				Format:        FormatRPM,
+			},
+		},
+		{
+			title: "rhel rt",
+			url:   "https://cdn.redhat.com/content/dist/rhel8/8/x86_64/rt/os/Packages/k/kernel-rt-devel-4.18.0-305.rt7.72.el8_4.x86_64.rpm",
+			expected: Info{
+				Family:        "rhel",
+				Name:          "kernel-rt-devel",
+				Flavour:       "rt",
+				KernelVersion: "4.18.0",
+				KernelRelease: "4.18.0-305.rt7.72.el8_4",
+				Version:       "4.18.0-305.rt7.72.el8_4",
+				Revision:      "305.rt7.72.el8_4",
+				Arch:          "x86_64",
+				Format:        FormatRPM,
+			},
+		},
+		{
+			title: "oracle uek",
+			url:   "http://yum.oracle.com/repo/OracleLinux/OL7/UEKR6/x86_64/getPackage/kernel-uek-devel-5.4.17-2036.102.0.2.el7uek.x86_64.rpm",
+			expected: Info{
+				Family:        "oracle",
+				Name:          "kernel-uek-devel",
+				Flavour:       "uek",
+				KernelVersion: "5.4.17",
+				KernelRelease: "5.4.17-2036.102.0.2.el7uek",
+				Version:       "5.4.17-2036.102.0.2.el7uek",
+				Revision:      "2036.102.0.2.el7uek",
+				Arch:          "x86_64",
+				Format:        FormatRPM,
+			},
+		},
+		{
+			title: "amazon",
+			url:   "http://amazonlinux.us-west-2.amazonaws.com/blobstore/0a4cacd0ba015b7d5feeda59e8f920cdf290a059c9087a350ae35369c0cbc4bf/kernel-devel-5.10.209-198.812.amzn2.x86_64.rpm",
+			expected: Info{
+				Family:        "amazon",
+				Name:          "kernel-devel",
+				KernelVersion: "5.10.209",
+				KernelRelease: "5.10.209-198.812.amzn2",
+				Version:       "5.10.209-198.812.amzn2",
+				Revision:      "198.812.amzn2",
+				Arch:          "x86_64",
+				Format:        FormatRPM,
+			},
+		},
+		{
+			title: "suse",
+			url:   "https://updates.suse.com/SUSE/Updates/SLE-SERVER/12-SP4-LTSS/x86_64/update/x86_64/kernel-default-devel-4.12.14-95.93.1.x86_64.rpm",
+			expected: Info{
+				Family:        "suse",
+				Name:          "kernel-default-devel",
+				Flavour:       "default",
+				KernelVersion: "4.12.14",
+				KernelRelease: "4.12.14-95.93.1",
+				Version:       "4.12.14-95.93.1",
+				Revision:      "95.93.1",
+				Arch:          "x86_64",
+				Format:        FormatRPM,
+			},
+		},
+		{
+			title: "suse noarch",
+			url:   "https://updates.suse.com/SUSE/Updates/SLE-Module-Basesystem/15-SP3/x86_64/update/noarch/kernel-devel-5.3.18-150300.59.63.1.noarch.rpm",
+			expected: Info{
+				Family:        "suse",
+				Name:          "kernel-devel",
+				KernelVersion: "5.3.18",
+				KernelRelease: "5.3.18-150300.59.63.1",
+				Version:       "5.3.18-150300.59.63.1",
+				Revision:      "150300.59.63.1",
+				Format:        FormatRPM,
+			},
+		},
+		{
+			title: "cos",
+			url:   "https://storage.googleapis.com/cos-tools/12739.68.0/kernel-headers.tgz",
+			expected: Info{
+				Family:   "cos",
+				Name:     "kernel-headers",
+				Revision: "12739.68.0",
+				Format:   FormatTarball,
+			},
+		},
+		{
+			title: "flatcar",
+			url:   "https://stable.release.flatcar-linux.net/amd64-usr/3510.2.0/flatcar_developer_container.bin.bz2",
+			expected: Info{
+				Family:   "flatcar",
+				Name:     "flatcar_developer_container",
+				Revision: "3510.2.0",
+				Arch:     "x86_64",
+				Format:   FormatContainerImage,
+			},
+		},
+		{
+			title: "minikube",
+			url:   "https://raw.githubusercontent.com/kubernetes/minikube/v1.26.0/deploy/iso/minikube-iso/board/minikube/x86_64/linux_x86_64_defconfig?kernel=5.10.57",
+			expected: Info{
+				Family:        "minikube",
+				Name:          "linux_x86_64_defconfig",
+				KernelVersion: "5.10.57",
+				KernelRelease: "5.10.57",
+				Revision:      "1.26.0",
+				Format:        FormatConfig,
+			},
+		},
+		{
+			title: "linuxkit",
+			url:   "https://github.com/linuxkit/linux/archive/v4.9.125.tar.gz",
+			expected: Info{
+				Family:        "linuxkit",
+				Name:          "linux",
+				KernelVersion: "4.9.125",
+				KernelRelease: "4.9.125",
+				Format:        FormatTarball,
+			},
+		},
+		{
+			title: "upstream",
+			url:   "https://cdn.kernel.org/pub/linux/kernel/v4.x/linux-4.19.202.tar.xz",
+			expected: Info{
+				Name:          "linux",
+				KernelVersion: "4.19.202",
+				KernelRelease: "4.19.202",
+				Format:        FormatTarball,
+			},
+		},
+		{
+			title: "unrecognized",
+			url:   "https://api.access.redhat.com/management/v1/packages/a853590121aeb01673488b6bb90fd69f1791f4feaf7f809b06d2d28bc38149b3/download",
+			err:   "unrecognized package https://api.access.redhat.com/management/v1/packages/a853590121aeb01673488b6bb90fd69f1791f4feaf7f809b06d2d28bc38149b3/download",
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			info, err := Parse(test.url)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, info)
+		})
+	}
+}
+
+func TestParseSimplified(t *testing.T) {
+	tests := []struct {
+		title    string
+		url      string
+		expected Info
+	}{
+		{
+			title: "ubuntu headers in a directory named after the flavour",
+			url:   "http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-headers-5.4.0-1048-gke_5.4.0-1048.50_amd64.deb",
+			expected: Info{
+				Family:        "ubuntu",
+				Name:          "linux-headers-5.4.0-1048-gke",
+				Flavour:       "gke",
+				KernelVersion: "5.4.0",
+				KernelRelease: "5.4.0-1048",
+				ABI:           "1048",
+				Version:       "5.4.0-1048.50",
+				Revision:      "50",
+				Arch:          "x86_64",
+				Format:        FormatDeb,
+			},
+		},
+		{
+			title: "ubuntu backport",
+			url:   "http://security.ubuntu.com/ubuntu/pool/main/l/linux-aws-5.15/linux-headers-5.15.0-1034-aws_5.15.0-1034.38~20.04.1_amd64.deb",
+			expected: Info{
+				Family:        "ubuntu",
+				Name:          "linux-headers-5.15.0-1034-aws",
+				Flavour:       "aws",
+				KernelVersion: "5.15.0",
+				KernelRelease: "5.15.0-1034",
+				ABI:           "1034",
+				Version:       "5.15.0-1034.38-20.04.1",
+				Revision:      "38-20.04.1",
+				Arch:          "x86_64",
+				Format:        FormatDeb,
+			},
+		},
+		{
+			title: "fedora in a directory named after the kernel",
+			url:   "https://kojipkgs.fedoraproject.org/packages/kernel/6.9.1/200.fc40/x86_64/kernel-devel-6.9.1-200.fc40.x86_64.rpm",
+			expected: Info{
+				Family:        "fedora",
+				Name:          "kernel-devel",
+				KernelVersion: "6.9.1",
+				KernelRelease: "6.9.1-200.fc40",
+				Version:       "6.9.1-200.fc40",
+				Revision:      "200.fc40",
+				Arch:          "x86_64",
+				Format:        FormatRPM,
+			},
+		},
+		{
+			title: "minikube",
+			url:   "https://raw.githubusercontent.com/kubernetes/minikube/v1.24.0/deploy/iso/minikube-iso/board/coreos/minikube/linux_defconfig?kernel=4.19.202",
+			expected: Info{
+				Family:        "minikube",
+				Name:          "linux_defconfig",
+				KernelVersion: "4.19.202",
+				KernelRelease: "4.19.202",
+				Revision:      "1.24.0",
+				Format:        FormatConfig,
+			},
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			info, err := Parse(util.SimplifyURL(test.url))
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, info)
+		})
+	}
+}
+
+func TestUname(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-headers-5.4.0-1048-gke_5.4.0-1048.50_amd64.deb", "5.4.0-1048-gke"},
+		{"http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-gke-headers-5.4.0-1048_5.4.0-1048.50_all.deb", ""},
+		{"http://security.ubuntu.com/ubuntu/pool/main/l/linux/linux-headers-5.4.0-124_5.4.0-124.140_all.deb", ""},
+		{"http://security.debian.org/pool/updates/main/l/linux/linux-headers-6.1.0-21-cloud-amd64_6.1.90-1_amd64.deb", "6.1.0-21-cloud-amd64"},
+		{"http://security.debian.org/pool/updates/main/l/linux/linux-headers-6.1.0-21-common_6.1.90-1_all.deb", ""},
+		{"http://security.debian.org/pool/updates/main/l/linux/linux-kbuild-6.1_6.1.90-1_amd64.deb", ""},
+		{"http://repo.gardenlinux.io/gardenlinux/pool/main/l/linux-5.15/linux-headers-5.15.114-gardenlinux-cloud-amd64_5.15.114-0gardenlinux3_amd64.deb", "5.15.114-gardenlinux-cloud-amd64"},
+		{"https://cdn.redhat.com/content/dist/rhel8/8/x86_64/baseos/os/Packages/k/kernel-devel-4.18.0-147.0.2.el8_1.x86_64.rpm", "4.18.0-147.0.2.el8_1.x86_64"},
+		{"https://mirrors.kernel.org/centos/8/BaseOS/aarch64/os/Packages/kernel-devel-4.18.0-305.el8.aarch64.rpm", "4.18.0-305.el8.aarch64"},
+		{"https://updates.suse.com/SUSE/Updates/SLE-SERVER/12-SP4-LTSS/x86_64/update/x86_64/kernel-default-devel-4.12.14-95.93.1.x86_64.rpm", "4.12.14-95.93-default"},
+		{"https://updates.suse.com/SUSE/Updates/SLE-Module-Basesystem/15-SP3/x86_64/update/noarch/kernel-devel-5.3.18-150300.59.63.1.noarch.rpm", ""},
+		{"https://storage.googleapis.com/cos-tools/12739.68.0/kernel-src.tar.gz", ""},
+		{"https://stable.release.flatcar-linux.net/amd64-usr/3510.2.0/flatcar_developer_container.bin.bz2", ""},
+		{"https://cdn.kernel.org/pub/linux/kernel/v4.x/linux-4.19.202.tar.xz", "4.19.202"},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.expected)
+		t.Run(name, func(t *testing.T) {
+			info, err := Parse(test.url)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, info.Uname())
+		})
+	}
+}
+
+func TestPackagesKernelVersion(t *testing.T) {
+	assert.Equal(t, "6.1.90", PackagesKernelVersion([]string{
+		"http://security.debian.org/pool/updates/main/l/linux/linux-headers-6.1.0-21-common_6.1.90-1_all.deb",
+		"http://security.debian.org/pool/updates/main/l/linux/linux-headers-6.1.0-21-amd64_6.1.90-1_amd64.deb",
+	}))
+	assert.Equal(t, "", PackagesKernelVersion([]string{
+		"https://stable.release.flatcar-linux.net/amd64-usr/3510.2.0/flatcar_developer_container.bin.bz2",
+	}))
+	assert.Equal(t, "", PackagesKernelVersion(nil))
+}
@@ -0,0 +1,307 @@
+package pkginfo
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	numericVersionRegex = regexp.MustCompile(`^\d+(?:\.\d+)*`)
+)
+
+// VersionLess reports whether the leading dotted numeric prefix of versionA,
+// such as "4.18.0" of "4.18.0-305.el8", is less than that of versionB.
+// Anything after the prefix is ignored; use RPMLess to order RPM versions.
+func VersionLess(versionA, versionB string) bool {
+	numericA := numericVersionRegex.FindString(versionA)
+	numericB := numericVersionRegex.FindString(versionB)
+
+	if numericA == "" || numericB == "" {
+		return numericB != ""
+	}
+
+	partsA := strings.Split(numericA, ".")
+	partsB := strings.Split(numericB, ".")
+
+	minNumParts := len(partsA)
+	if len(partsB) < minNumParts {
+		minNumParts = len(partsB)
+	}
+
+	for i := 0; i < minNumParts; i++ {
+		partA, _ := strconv.Atoi(partsA[i])
+		partB, _ := strconv.Atoi(partsB[i])
+		if partA != partB {
+			return partA < partB
+		}
+	}
+	return len(partsA) < len(partsB)
+}
+
+// RPMLess reports whether the RPM version evrA is older than evrB, as
+// compared by RPMCompare.
+func RPMLess(evrA, evrB string) bool {
+	return RPMCompare(evrA, evrB) < 0
+}
+
+// RPMCompare compares the RPM versions evrA and evrB, given in the
+// "epoch:version-release" form in which the epoch and the release are
+// optional, such as "4.18.0-305.el8". It returns -1, 0 or 1 when evrA is older
+// than, the same as, or newer than evrB. A missing epoch is 0, and releases
+// are only compared if both versions have one, as rpm does.
+func RPMCompare(evrA, evrB string) int {
+	epochA, versionA, releaseA := splitEVR(evrA)
+	epochB, versionB, releaseB := splitEVR(evrB)
+
+	if cmp := RPMVersionCompare(epochA, epochB); cmp != 0 {
+		return cmp
+	}
+	if cmp := RPMVersionCompare(versionA, versionB); cmp != 0 {
+		return cmp
+	}
+	if releaseA == "" || releaseB == "" {
+		return 0
+	}
+	return RPMVersionCompare(releaseA, releaseB)
+}
+
+// splitEVR splits the given RPM version into its epoch, version and release.
+func splitEVR(evr string) (string, string, string) {
+	var epoch = "0"
+	if index := strings.Index(evr, ":"); index >= 0 {
+		if index > 0 {
+			epoch = evr[:index]
+		}
+		evr = evr[index+1:]
+	}
+
+	var release string
+	if index := strings.LastIndex(evr, "-"); index >= 0 {
+		evr, release = evr[:index], evr[index+1:]
+	}
+	return epoch, evr, release
+}
+
+// RPMVersionCompare compares the RPM version or release strings a and b, as
+// rpmvercmp does. It returns -1, 0 or 1 when a is older than, the same as, or
+// newer than b.
+//
+// Both strings are split into segments of digits and of letters, separated
+// by any other characters, and compared segment by segment. Numeric segments
+// are compared as numbers, and are newer than letter segments. A "~" sorts
+// before anything, even the end of the string, such that "1.0~rc1" is older
+// than "1.0". A "^" sorts after the end of the string, but before anything
+// else, such that "1.0^git1" is newer than "1.0", but older than "1.0.1".
+func RPMVersionCompare(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	for {
+		a = strings.TrimLeftFunc(a, isRPMSeparator)
+		b = strings.TrimLeftFunc(b, isRPMSeparator)
+
+		// A tilde sorts before everything else.
+		if strings.HasPrefix(a, "~") || strings.HasPrefix(b, "~") {
+			if !strings.HasPrefix(a, "~") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "~") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		// A caret sorts after the end of the string, but before everything
+		// else.
+		if strings.HasPrefix(a, "^") || strings.HasPrefix(b, "^") {
+			if a == "" {
+				return -1
+			}
+			if b == "" {
+				return 1
+			}
+			if !strings.HasPrefix(a, "^") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "^") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		if a == "" || b == "" {
+			break
+		}
+
+		var (
+			numeric = isDigit(rune(a[0]))
+			inClass = isLetter
+		)
+		if numeric {
+			inClass = isDigit
+		}
+		segmentA := leadingRun(a, inClass)
+		segmentB := leadingRun(b, inClass)
+
+		// Segments of different classes: numeric segments are newer.
+		if segmentB == "" {
+			if numeric {
+				return 1
+			}
+			return -1
+		}
+
+		var cmp int
+		if numeric {
+			cmp = compareNumeric(segmentA, segmentB)
+		} else {
+			cmp = strings.Compare(segmentA, segmentB)
+		}
+		if cmp != 0 {
+			return cmp
+		}
+
+		a, b = a[len(segmentA):], b[len(segmentB):]
+	}
+
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return -1
+	default:
+		return 1
+	}
+}
+
+// compareNumeric compares the given strings of digits as numbers of any size.
+func compareNumeric(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// leadingRun returns the longest prefix of s whose characters are all in the
+// class reported by the given function.
+func leadingRun(s string, inClass func(rune) bool) string {
+	for index, char := range s {
+		if !inClass(char) {
+			return s[:index]
+		}
+	}
+	return s
+}
+
+func isDigit(char rune) bool {
+	return '0' <= char && char <= '9'
+}
+
+func isLetter(char rune) bool {
+	return ('a' <= char && char <= 'z') || ('A' <= char && char <= 'Z')
+}
+
+// isRPMSeparator reports whether the given character separates segments of an
+// RPM version.
+func isRPMSeparator(char rune) bool {
+	return !isDigit(char) && !isLetter(char) && char != '~' && char != '^'
+}
+
+// DebianLess reports whether the Debian package version versionA is older
+// than versionB, as compared by DebianCompare.
+func DebianLess(versionA, versionB string) bool {
+	return DebianCompare(versionA, versionB) < 0
+}
+
+// DebianCompare compares the Debian package versions a and b, given in the
+// "epoch:upstream_version-debian_revision" form in which the epoch and the
+// revision are optional, such as "5.10.218-1" or "4.4-4~bpo8+1". It returns
+// -1, 0 or 1 when a is older than, the same as, or newer than b, as
+// dpkg --compare-versions does. A missing epoch is 0, and a missing revision
+// is the same as a revision of 0.
+func DebianCompare(a, b string) int {
+	epochA, upstreamA, revisionA := splitDebianVersion(a)
+	epochB, upstreamB, revisionB := splitDebianVersion(b)
+
+	if cmp := compareNumeric(epochA, epochB); cmp != 0 {
+		return cmp
+	}
+	if cmp := debianVersionCompare(upstreamA, upstreamB); cmp != 0 {
+		return cmp
+	}
+	return debianVersionCompare(revisionA, revisionB)
+}
+
+// splitDebianVersion splits the given Debian package version into its epoch,
+// upstream version and revision.
+func splitDebianVersion(version string) (string, string, string) {
+	var epoch = "0"
+	if index := strings.Index(version, ":"); index >= 0 {
+		if index > 0 {
+			epoch = version[:index]
+		}
+		version = version[index+1:]
+	}
+
+	var revision string
+	if index := strings.LastIndex(version, "-"); index >= 0 {
+		version, revision = version[:index], version[index+1:]
+	}
+	return epoch, version, revision
+}
+
+// debianVersionCompare compares the upstream versions or revisions a and b,
+// as the verrevcmp function of dpkg does. Both strings are compared as
+// alternating non-digit and digit parts. Non-digit parts are compared
+// character by character, where letters sort before all other characters, and
+// "~" sorts before anything, even the end of the part. Digit parts are
+// compared as numbers.
+func debianVersionCompare(a, b string) int {
+	for a != "" || b != "" {
+		for (a != "" && !isDigit(rune(a[0]))) || (b != "" && !isDigit(rune(b[0]))) {
+			orderA, orderB := debianOrder(a), debianOrder(b)
+			if orderA != orderB {
+				if orderA < orderB {
+					return -1
+				}
+				return 1
+			}
+			a, b = a[1:], b[1:]
+		}
+
+		digitsA := leadingRun(a, isDigit)
+		digitsB := leadingRun(b, isDigit)
+		if cmp := compareNumeric(digitsA, digitsB); cmp != 0 {
+			return cmp
+		}
+		a, b = a[len(digitsA):], b[len(digitsB):]
+	}
+	return 0
+}
+
+// debianOrder returns the sort weight of the first character of the given
+// part of a Debian version, as the order function of dpkg does.
+func debianOrder(s string) int {
+	if s == "" {
+		return 0
+	}
+	switch char := rune(s[0]); {
+	case isDigit(char):
+		return 0
+	case isLetter(char):
+		return int(char)
+	case char == '~':
+		return -1
+	default:
+		return int(char) + 256
+	}
+}
@@ -0,0 +1,244 @@
+package pkginfo
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRPMVersionCompare(t *testing.T) {
+	// Cases from the rpmvercmp tests of rpm itself.
+	tests := []struct {
+		a        string
+		b        string
+		expected int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "2.0", -1},
+		{"2.0", "1.0", 1},
+		{"2.0.1", "2.0.1", 0},
+		{"2.0", "2.0.1", -1},
+		{"2.0.1a", "2.0.1a", 0},
+		{"2.0.1a", "2.0.1", 1},
+		{"5.5p1", "5.5p2", -1},
+		{"5.5p10", "5.5p1", 1},
+		{"10xyz", "10.1xyz", -1},
+		{"xyz10", "xyz10.1", -1},
+		{"xyz.4", "8", -1},
+		{"8", "xyz.4", 1},
+		{"5.5p1", "5.6p1", -1},
+		{"6.0.rc1", "6.0", 1},
+		{"10a2", "10b2", -1},
+		{"1.0aa", "1.0a", 1},
+		{"10.0001", "10.1", 0},
+		{"10.0039", "10.39", 0},
+		{"4.999.9", "5.0", -1},
+		{"20101121", "20101122", -1},
+		{"2_0", "2_0", 0},
+		{"2.0", "2_0", 0},
+		{"a", "a", 0},
+		{"a+", "a_", 0},
+		{"+", "_", 0},
+		{"1.0~rc1", "1.0~rc1", 0},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0~rc1~git123", "1.0~rc1", -1},
+		{"1.0^", "1.0", 1},
+		{"1.0^git1", "1.0", 1},
+		{"1.0^git1", "1.01", -1},
+		{"1.0^20160101", "1.0.1", -1},
+		{"1.0^20160101^git1", "1.0^20160101", 1},
+		{"1.0~rc1^git1", "1.0~rc1", 1},
+		{"1.0^git1~pre", "1.0^git1", -1},
+		{"4.18.0", "4.18.0", 0},
+		{"305.el8", "372.el8", -1},
+		{"372.26.1.el8_6", "372.9.1.el8_6", 1},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s %s", index+1, test.a, test.b)
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, RPMVersionCompare(test.a, test.b))
+			assert.Equal(t, -test.expected, RPMVersionCompare(test.b, test.a))
+		})
+	}
+}
+
+func TestRPMCompare(t *testing.T) {
+	tests := []struct {
+		a        string
+		b        string
+		expected int
+	}{
+		{"4.18.0-305.el8", "4.18.0-372.el8", -1},
+		{"4.18.0-305.el8", "4.18.0-305.el8", 0},
+		{"4.18.0", "4.18.0-305.el8", 0},
+		{"1:4.18.0-305.el8", "4.18.0-372.el8", 1},
+		{"0:4.18.0-305.el8", "4.18.0-305.el8", 0},
+		{":4.18.0-305.el8", "4.18.0-305.el8", 0},
+		{"4.18.0-348.7.1.el8_5", "5.14.0-70.13.1.el9_0", -1},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s %s", index+1, test.a, test.b)
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, RPMCompare(test.a, test.b))
+			assert.Equal(t, -test.expected, RPMCompare(test.b, test.a))
+		})
+	}
+}
+
+// TestRPMCorpus sorts packages taken from the rhel*.txt and amazon*.txt
+// package lists, which are listed here oldest first.
+func TestRPMCorpus(t *testing.T) {
+	tests := []struct {
+		title    string
+		packages []string
+	}{
+		{
+			title: "rhel8",
+			packages: []string{
+				"https://cdn.redhat.com/content/dist/rhel8/8/x86_64/baseos/os/Packages/k/kernel-devel-4.18.0-80.el8.x86_64.rpm",
+				"https://cdn.redhat.com/content/dist/rhel8/8/x86_64/baseos/os/Packages/k/kernel-devel-4.18.0-80.11.1.el8_0.x86_64.rpm",
+				"https://cdn.redhat.com/content/dist/rhel8/8/x86_64/baseos/os/Packages/k/kernel-devel-4.18.0-147.0.2.el8_1.x86_64.rpm",
+				"https://cdn.redhat.com/content/dist/rhel8/8/x86_64/baseos/os/Packages/k/kernel-devel-4.18.0-147.0.3.el8_1.x86_64.rpm",
+				"https://cdn.redhat.com/content/dist/rhel8/8/x86_64/baseos/os/Packages/k/kernel-devel-4.18.0-147.5.1.el8_1.x86_64.rpm",
+				"https://cdn.redhat.com/content/dist/rhel8/8/x86_64/baseos/os/Packages/k/kernel-devel-4.18.0-193.1.2.el8_2.x86_64.rpm",
+				"https://cdn.redhat.com/content/eus/rhel8/8.4/x86_64/baseos/os/Packages/k/kernel-devel-4.18.0-305.40.1.el8_4.x86_64.rpm",
+				"https://cdn.redhat.com/content/eus/rhel8/8.4/x86_64/baseos/os/Packages/k/kernel-devel-4.18.0-305.49.1.el8_4.x86_64.rpm",
+				"https://cdn.redhat.com/content/eus/rhel8/8.4/x86_64/baseos/os/Packages/k/kernel-devel-4.18.0-305.71.1.el8_4.x86_64.rpm",
+				"https://cdn.redhat.com/content/eus/rhel8/8.4/x86_64/baseos/os/Packages/k/kernel-devel-4.18.0-305.76.1.el8_4.x86_64.rpm",
+				"https://cdn.redhat.com/content/dist/rhel8/8/x86_64/baseos/os/Packages/k/kernel-devel-4.18.0-348.7.1.el8_5.x86_64.rpm",
+				"https://cdn.redhat.com/content/dist/rhel8/8/x86_64/baseos/os/Packages/k/kernel-devel-4.18.0-372.26.1.el8_6.x86_64.rpm",
+				"https://cdn.redhat.com/content/dist/layered/rhel8/x86_64/rhocp/4.11/os/Packages/k/kernel-devel-4.18.0-372.49.1.el8_6.x86_64.rpm",
+				"https://cdn.redhat.com/content/dist/layered/rhel8/x86_64/rhocp/4.12/os/Packages/k/kernel-devel-4.18.0-372.109.1.el8_6.x86_64.rpm",
+			},
+		},
+		{
+			title: "rhel7",
+			packages: []string{
+				"https://cdn.redhat.com/content/dist/rhel/server/7/7Server/x86_64/os/Packages/k/kernel-devel-3.10.0-123.6.3.el7.x86_64.rpm",
+				"https://cdn.redhat.com/content/dist/rhel/server/7/7Server/x86_64/os/Packages/k/kernel-devel-3.10.0-229.1.2.el7.x86_64.rpm",
+				"https://cdn.redhat.com/content/dist/rhel/server/7/7Server/x86_64/os/Packages/k/kernel-devel-3.10.0-514.21.2.el7.x86_64.rpm",
+				"https://cdn.redhat.com/content/dist/rhel/server/7/7Server/x86_64/os/Packages/k/kernel-devel-3.10.0-514.26.1.el7.x86_64.rpm",
+				"https://cdn.redhat.com/content/dist/rhel/server/7/7Server/x86_64/os/Packages/k/kernel-devel-3.10.0-1160.41.1.el7.x86_64.rpm",
+				"https://cdn.redhat.com/content/dist/rhel/server/7/7Server/x86_64/os/Packages/k/kernel-devel-3.10.0-1160.59.1.el7.x86_64.rpm",
+			},
+		},
+		{
+			title: "rhel rt",
+			packages: []string{
+				"https://cdn.redhat.com/content/dist/layered/rhel8/x86_64/rhocp/4.11/os/Packages/k/kernel-rt-devel-4.18.0-372.41.1.rt7.198.el8_6.x86_64.rpm",
+				"https://cdn.redhat.com/content/dist/layered/rhel8/x86_64/rhocp/4.11/os/Packages/k/kernel-rt-devel-4.18.0-372.58.1.rt7.216.el8_6.x86_64.rpm",
+				"https://cdn.redhat.com/content/dist/layered/rhel8/x86_64/rhocp/4.11/os/Packages/k/kernel-rt-devel-4.18.0-372.64.1.rt7.222.el8_6.x86_64.rpm",
+				"https://cdn.redhat.com/content/dist/layered/rhel8/x86_64/rhocp/4.12/os/Packages/k/kernel-rt-devel-4.18.0-372.107.1.rt7.267.el8_6.x86_64.rpm",
+				"https://ocp-artifacts.hosts.prod.psi.rdu2.redhat.com/pub/RHOCP/plashets/4.13/stream/el9/latest/x86_64/os/Packages/kernel-rt-5.14.0-284.13.1.rt14.298.el9_2__x86_64__fd431d51/kernel-rt-devel-5.14.0-284.13.1.rt14.298.el9_2.x86_64.rpm",
+				"https://ocp-artifacts.hosts.prod.psi.rdu2.redhat.com/pub/RHOCP/plashets/4.13/stream/el9/latest/x86_64/os/Packages/kernel-rt-5.14.0-284.16.1.rt14.301.el9_2__x86_64__fd431d51/kernel-rt-devel-5.14.0-284.16.1.rt14.301.el9_2.x86_64.rpm",
+			},
+		},
+		{
+			title: "amazon",
+			packages: []string{
+				"http://amazonlinux.us-west-2.amazonaws.com/blobstore/ae757f139e69f930fe9f703974265e913367cc2c798b69d5dd5cf062992c80e4/kernel-devel-4.9.75-1.56.amzn2.x86_64.rpm",
+				"http://amazonlinux.us-west-2.amazonaws.com/blobstore/fad2de4ee1cb497b89b8a872b6f2b3ca6b973f1cf9223dd4f575b54365d9d0ad/kernel-devel-4.14.55-68.37.amzn2.x86_64.rpm",
+				"http://amazonlinux.us-west-2.amazonaws.com/blobstore/8ab1b036e97e9b6897464be1cbbb9fa4a3d6be0c81aed73a425b169b8a5ca04c/kernel-devel-4.14.181-140.257.amzn2.x86_64.rpm",
+				"http://amazonlinux.us-west-2.amazonaws.com/blobstore/afcce9722d7797b3bad6982cdddcdc16e088c2aa3609843780f765c3d7fffed8/kernel-devel-4.14.231-173.360.amzn2.x86_64.rpm",
+				"http://amazonlinux.us-west-2.amazonaws.com/blobstore/3e40203e849418d9ac9c8844f5d73c0d3e7224bb15dbecfb07fab3f2b5a1e156/kernel-devel-4.14.328-248.540.amzn2.x86_64.rpm",
+				"http://amazonlinux.us-west-2.amazonaws.com/blobstore/7e58be4d6f8dbe8e289989b65b940f11da083766b6a2d2c7cc8e45bb2da6a909/kernel-devel-4.14.344-262.563.amzn2.x86_64.rpm",
+				"http://amazonlinux.us-west-2.amazonaws.com/blobstore/117ddd7ca04faa044ae9713b7ddcfd1f1531242e6d4aedfe3d8646acc5254905/kernel-devel-5.4.95-42.163.amzn2.x86_64.rpm",
+				"http://amazonlinux.us-west-2.amazonaws.com/blobstore/76b33544679733c2c446ba38133cc48ed69b51bca09667ab89dbc4a89b7d1f6a/kernel-devel-5.4.129-63.229.amzn2.x86_64.rpm",
+				"http://amazonlinux.us-west-2.amazonaws.com/blobstore/c72486b0127a4de4d3a833f76dcad52e779a041a8a73c481c43d5944418e2aae/kernel-devel-5.4.273-186.370.amzn2.x86_64.rpm",
+				"http://amazonlinux.us-west-2.amazonaws.com/blobstore/acae53bf62f5acdba97c99e8a5354690245ef9c46d95eacc972e15f62febe5f2/kernel-devel-5.10.50-44.132.amzn2.x86_64.rpm",
+				"http://amazonlinux.us-west-2.amazonaws.com/blobstore/18bb3125c6eea2834e9b98f374326e548238dbf128f873671e8d30c26ca48081/kernel-devel-5.10.93-87.444.amzn2.x86_64.rpm",
+				"http://amazonlinux.us-west-2.amazonaws.com/blobstore/a5cd2db69f642d9e8bde004878d19cb0446a37f2d3f1b5aef7ad6a4c6dd45eb5/kernel-devel-5.10.102-99.473.amzn2.x86_64.rpm",
+				"http://amazonlinux.us-west-2.amazonaws.com/blobstore/8b98e4a09bd12f72fc922c7a5c9de0f34cdb1f499e95f8caba6e4144e15d0f91/kernel-devel-5.10.197-186.748.amzn2.x86_64.rpm",
+			},
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			evrs := make(map[string]string, len(test.packages))
+			for _, pkg := range test.packages {
+				info, err := Parse(pkg)
+				require.NoError(t, err)
+				evrs[pkg] = info.Version
+			}
+
+			sorted := make([]string, len(test.packages))
+			for index, pkg := range test.packages {
+				sorted[len(sorted)-1-index] = pkg
+			}
+			sort.SliceStable(sorted, func(i, j int) bool {
+				return RPMLess(evrs[sorted[i]], evrs[sorted[j]])
+			})
+			assert.Equal(t, test.packages, sorted)
+		})
+	}
+}
+
+func TestDebianCompare(t *testing.T) {
+	// Cases from the version tests of dpkg itself.
+	tests := []struct {
+		a        string
+		b        string
+		expected int
+	}{
+		{"0", "0", 0},
+		{"0", "00", 0},
+		{"1.2.3", "1.2.3", 0},
+		{"4.4.3-2", "4.4.3-2", 0},
+		{"1:2ab:5", "1:2ab:5", 0},
+		{"7:1-a:b-5", "7:1-a:b-5", 0},
+		{"57:1.2.3abYZ+~-4-5", "57:1.2.3abYZ+~-4-5", 0},
+		{"1.2.3", "0:1.2.3", 0},
+		{"1.2.3", "1.2.3-0", 0},
+		{"009", "9", 0},
+		{"009ab5", "9ab5", 0},
+		{"1.2.3", "1.2.3-1", -1},
+		{"1.2.3", "1.2.4", -1},
+		{"1.2.4", "1.2.3", 1},
+		{"1.2.24", "1.2.3", 1},
+		{"0.10.0", "0.8.7", 1},
+		{"3.2", "2.3", 1},
+		{"1.3.2a", "1.3.2", 1},
+		{"0.5.0~git", "0.5.0~git2", -1},
+		{"2a", "21", -1},
+		{"1.2a+~bCd3", "1.2a++", -1},
+		{"1.2a+~bCd3", "1.2a+~", 1},
+		{"5:2", "304-2", 1},
+		{"5:2", "304:2", -1},
+		{"25:2", "3:2", 1},
+		{"1:2:123", "1:12:3", -1},
+		{"1.2-5", "1.2-3-5", -1},
+		{"5.10", "5.005", 1},
+		{"3a9.8", "3.10.2", -1},
+		{"3a9.8", "3~10", 1},
+		{"1.4+OOo3.0.0~", "1.4+OOo3.0.0-4", -1},
+		{"2.4.7-1", "2.4.7-z", -1},
+		{"1.002-1+b2", "1.00", 1},
+		{"1.0~rc1", "1.0", -1},
+		{"~~", "~~a", -1},
+		{"~~a", "~", -1},
+		{"~", "", -1},
+		{"a", "b", -1},
+		{"1.0a", "1.0+", -1},
+		{"4.4-4~bpo8+1", "4.4-4", -1},
+		{"1.0+b1", "1.0", 1},
+		{"5.10.209-2", "5.10.218-1", -1},
+		{"6.1.0-21", "6.1.0-9", 1},
+		{"5.4.0-1048.50", "5.4.0-1048.50~18.04.1", 1},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s %s", index+1, test.a, test.b)
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, DebianCompare(test.a, test.b))
+			assert.Equal(t, -test.expected, DebianCompare(test.b, test.a))
+		})
+	}
+}
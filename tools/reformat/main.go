@@ -44,7 +44,7 @@ func mainCmd() error {
 		return errors.Wrap(err, "loading package URLs")
 	}
 
-	packageSets, err := reformatter(urls)
+	packageSets, err := reformatter(urls, nil)
 	if err != nil {
 		return errors.Wrap(err, "reformatting")
 	}
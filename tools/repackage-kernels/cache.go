@@ -0,0 +1,98 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/stackrox/kernel-packer/tools/config/manifest"
+	"github.com/stackrox/kernel-packer/tools/packer"
+)
+
+// cacheOptions controls which build cache entries are still up to date.
+type cacheOptions struct {
+	// fingerprinter computes the packer fingerprint of each build.
+	fingerprinter *packer.Fingerprinter
+
+	// forceKinds are the kinds whose cached builds are always rebuilt.
+	forceKinds map[string]struct{}
+}
+
+// newCacheOptions reads the packer sources from the given packer directory.
+// The given kinds, separated by commas, will always be rebuilt.
+func newCacheOptions(packerDir string, forceKinds string) (cacheOptions, error) {
+	fingerprinter, err := packer.NewFingerprinter(packerDir)
+	if err != nil {
+		return cacheOptions{}, err
+	}
+
+	opts := cacheOptions{
+		fingerprinter: fingerprinter,
+		forceKinds:    make(map[string]struct{}),
+	}
+	for _, kind := range strings.Split(forceKinds, ",") {
+		if kind = strings.TrimSpace(kind); kind != "" {
+			opts.forceKinds[kind] = struct{}{}
+		}
+	}
+
+	return opts, nil
+}
+
+// fingerprint returns the packer fingerprint for the given builder.
+func (o cacheOptions) fingerprint(builder manifest.Builder) (string, error) {
+	return o.fingerprinter.Fingerprint(builder.Kind, builder.Image)
+}
+
+// loadCache loads the build cache from the given cache directory, and drops
+// every entry that has to be rebuilt, because the packer has changed since the
+// entry was built, or because its kind is forced to be rebuilt. The ids of the
+// dropped entries are returned alongside the remaining cache. Entries without
+// a fingerprint predate fingerprinting, and are rebuilt, as it is not known
+// which packer built them.
+func loadCache(cacheDir string, buildManifest manifest.Manifest, opts cacheOptions) (manifest.Manifest, []string, error) {
+	var cacheFile = filepath.Join(cacheDir, "cache.yml")
+
+	buildCache, err := manifest.Load(cacheFile)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to load build cache")
+	}
+
+	var (
+		fresh = manifest.New()
+		stale []string
+	)
+
+	for _, id := range buildCache.SortedIDs() {
+		var (
+			cached          = buildCache[id]
+			builder, exists = buildManifest[id]
+		)
+
+		if exists {
+			if _, found := opts.forceKinds[builder.Kind]; found {
+				stale = append(stale, id)
+				continue
+			}
+
+			if cached.Fingerprint == "" {
+				stale = append(stale, id)
+				continue
+			}
+
+			fingerprint, err := opts.fingerprint(builder)
+			if err != nil {
+				return nil, nil, err
+			}
+			if fingerprint != cached.Fingerprint {
+				stale = append(stale, id)
+				continue
+			}
+		}
+
+		fresh[id] = cached
+	}
+
+	return fresh, stale, nil
+}
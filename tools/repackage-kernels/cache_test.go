@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stackrox/kernel-packer/tools/config/manifest"
+)
+
+func TestLoadCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	packerDir := filepath.Join(dir, "packers")
+	require.NoError(t, os.MkdirAll(packerDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(packerDir, "entrypoint"), []byte(testEntrypoint), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(packerDir, "Dockerfile"), []byte("FROM debian:buster\n"), 0644))
+
+	opts, err := newCacheOptions(packerDir, "")
+	require.NoError(t, err)
+	fingerprint, err := opts.fingerprint(manifest.Builder{Kind: "redhat"})
+	require.NoError(t, err)
+
+	buildManifest := manifest.Manifest{
+		"fresh":   {Kind: "redhat"},
+		"stale":   {Kind: "redhat"},
+		"legacy":  {Kind: "redhat"},
+		"forced":  {Kind: "ubuntu"},
+		"unknown": {Kind: "gentoo"},
+	}
+	buildCache := manifest.Manifest{
+		"fresh":   {Kind: "redhat", Fingerprint: fingerprint},
+		"stale":   {Kind: "redhat", Fingerprint: "0000"},
+		"legacy":  {Kind: "redhat"},
+		"forced":  {Kind: "ubuntu"},
+		"unknown": {Kind: "gentoo"},
+		"removed": {Kind: "redhat", Fingerprint: "0000"},
+	}
+	require.NoError(t, manifest.Save(buildCache, filepath.Join(dir, "cache.yml")))
+
+	opts, err = newCacheOptions(packerDir, "ubuntu, debian")
+	require.NoError(t, err)
+
+	fresh, stale, err := loadCache(dir, buildManifest, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fresh", "removed"}, fresh.SortedIDs())
+	assert.Equal(t, []string{"forced", "legacy", "stale", "unknown"}, stale)
+}
+
+const testEntrypoint = `#!/usr/bin/env bash
+main() {
+    repackage_"$2" "$@"
+}
+
+repackage_redhat() {
+    rpm2cpio
+}
+
+repackage_ubuntu() {
+    dpkg -x
+}
+
+main "$@"
+`
+
+func TestSaveCacheFragment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	entry := manifest.Builder{
+		Kind:       "redhat",
+		Packages:   []string{"kernel-devel-4.18.0-305.el8.x86_64.rpm"},
+		Bundle:     "bundle-4.18.0-305.el8.x86_64.tgz",
+		NodeIndex:  2,
+		NodeSource: "github-actions",
+	}
+	require.NoError(t, saveCacheFragment("sha", entry, dir))
+
+	mf, err := manifest.CombineDir(dir)
+	require.NoError(t, err)
+	require.Len(t, mf, 1)
+	for _, builder := range mf {
+		assert.Equal(t, entry, builder)
+	}
+}
@@ -1,27 +1,36 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sort"
-	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/pkg/errors"
 
+	"github.com/stackrox/kernel-packer/tools/bundle"
 	"github.com/stackrox/kernel-packer/tools/command"
 	"github.com/stackrox/kernel-packer/tools/config/manifest"
 )
 
 var (
-	// nodeCount is the total number if CircleCI build nodes in the current job.
-	nodeCount = envInt("CIRCLE_NODE_TOTAL", 1)
+	// nodeCount is the total number of CI build nodes in the current job.
+	nodeCount = 1
 
-	// nodeIndex is which CircleCI build node the current job is running on.
-	nodeIndex = envInt("CIRCLE_NODE_INDEX", 0)
+	// nodeIndex is which CI build node the current job is running on.
+	nodeIndex = 0
+
+	// nodeSource names where nodeCount and nodeIndex were taken from.
+	nodeSource = "default"
 )
 
 func main() {
@@ -35,17 +44,76 @@ func mainCmd() error {
 	var (
 		flagManifest     = flag.String("manifest", "", "Path to build manifest file.")
 		flagCacheDir     = flag.String("cache-dir", "", "Path to build cache directory.")
-		flagAction       = flag.String("action", "build", `Action to take. (one of "build", "combine", or "files")`)
+		flagAction       = flag.String("action", "build", `Action to take. (one of "build", "combine", "files", or "plan")`)
 		flagPrefix       = flag.String("prefix", "", "Prefix to prepend to file list.")
 		flagPkgDir       = flag.String("pkg-dir", "", "Path to downloaded package dir.")
 		flagBundleDir    = flag.String("bundle-dir", "", "Path to bundle dir.")
 		flagIgnoreErrors = flag.Bool("ignore-errors", false, "Ignore repackaging errors")
+		flagJobs         = flag.Int("jobs", 1, "Number of builds to run in parallel.")
+		flagReport       = flag.String("report", "", "Path to write a JSON build report to.")
+		flagReportJUnit  = flag.String("report-junit", "", "Path to write a JUnit XML build report to.")
+		flagTimeout      = flag.Duration("timeout", 0, "Maximum duration of a single build. (0 for no limit)")
+		flagRetries      = flag.Int("retries", 0, "Number of times to retry a build that failed for a transient reason.")
+		flagRetryBackoff = flag.Duration("retry-backoff", 30*time.Second, "Delay before the first retry, doubled for every subsequent retry.")
+		flagTimeoutRetry = flag.Int("timeout-retries", 1, "Number of the retries that may follow a build that timed out.")
+		flagRuntime      = flag.String("runtime", "docker", `Runtime used to run the packer. (one of "docker", "podman", "nerdctl", or "native")`)
+		flagSharding     = flag.String("sharding", "balanced", `Strategy for splitting builds across build nodes. (one of "balanced" or "round-robin")`)
+		flagHistory      = flag.String("history", "", "Glob pattern of previous JSON build reports, used to balance builds across build nodes.")
+		flagShardIndex   = flag.Int("shard-index", -1, "Index of this build node. (detected from the CI environment by default)")
+		flagShardTotal   = flag.Int("shard-total", -1, "Total number of build nodes. (detected from the CI environment by default)")
+		flagPackerDir    = flag.String("packer-dir", "packers", "Path to packer sources, used to detect stale cache entries.")
+		flagForceKinds   = flag.String("force-kinds", "", "Comma separated list of kinds to rebuild, even if they are cached.")
+		flagPackageLists = flag.String("package-lists", "kernel-package-lists", "Path to package lists, used to record the source URL of each package.")
 	)
 	flag.Parse()
 
+	var err error
+	nodeIndex, nodeCount, nodeSource, err = detectNode(*flagShardIndex, *flagShardTotal)
+	if err != nil {
+		return err
+	}
+
+	shard := shardOptions{
+		strategy:    *flagSharding,
+		historyGlob: *flagHistory,
+	}
+
+	runtime, err := command.GetRuntime(*flagRuntime)
+	if err != nil {
+		return err
+	}
+
+	// The combine action works on the build cache only.
+	var cache cacheOptions
+	if *flagAction != "combine" {
+		cache, err = newCacheOptions(*flagPackerDir, *flagForceKinds)
+		if err != nil {
+			return err
+		}
+	}
+
 	switch *flagAction {
 	case "build":
-		err := buildCmd(*flagManifest, *flagCacheDir, *flagPkgDir, *flagBundleDir)
+		if *flagJobs < 1 {
+			return errors.New("jobs must be at least 1")
+		}
+		err := buildCmd(buildOptions{
+			manifestFile: *flagManifest,
+			cacheDir:     *flagCacheDir,
+			pkgDir:       *flagPkgDir,
+			bundleDir:    *flagBundleDir,
+			jobs:         *flagJobs,
+			reportFile:   *flagReport,
+			junitFile:    *flagReportJUnit,
+			timeout:      *flagTimeout,
+			retries:      *flagRetries,
+			retryBackoff: *flagRetryBackoff,
+			timeoutRetry: *flagTimeoutRetry,
+			runtime:      runtime,
+			shard:        shard,
+			cache:        cache,
+			packageLists: *flagPackageLists,
+		})
 		if err != nil && *flagIgnoreErrors {
 			fmt.Fprintf(os.Stderr, "ignoring build error: %v", err)
 			return nil
@@ -56,91 +124,320 @@ func mainCmd() error {
 		return combineCmd(*flagCacheDir)
 
 	case "files":
-		return filesCmd(*flagManifest, *flagCacheDir, *flagPrefix)
+		return filesCmd(*flagManifest, *flagCacheDir, *flagPrefix, shard, cache)
+
+	case "plan":
+		return planCmd(os.Stdout, *flagManifest, *flagCacheDir, *flagPkgDir, *flagBundleDir, runtime, shard, cache)
 
 	default:
 		return errors.New("unknown action")
 	}
 }
 
+// buildOptions holds the settings for a single run of the build action.
+type buildOptions struct {
+	manifestFile string
+	cacheDir     string
+	pkgDir       string
+	bundleDir    string
+
+	// jobs is the number of builds to run at the same time.
+	jobs int
+
+	// reportFile and junitFile are the optional paths that the JSON and
+	// JUnit XML build reports are written to.
+	reportFile string
+	junitFile  string
+
+	// timeout is the maximum duration of a single build, or 0 for no limit.
+	timeout time.Duration
+
+	// retries is the number of times a build that failed for a transient
+	// reason is retried, waiting retryBackoff before the first retry.
+	retries      int
+	retryBackoff time.Duration
+
+	// timeoutRetry is how many of those retries may follow a build that
+	// timed out, as a build that hangs does so every time.
+	timeoutRetry int
+
+	// runtime is used to run the packer for every build.
+	runtime command.Runtime
+
+	// shard selects which builds run on this build node.
+	shard shardOptions
+
+	// cache selects which cached builds are still up to date.
+	cache cacheOptions
+
+	// packageLists is the directory of package lists that sourceURLs are
+	// loaded from. sourceURLs holds the crawled URL of each package, keyed by
+	// its simplified name, and is recorded in the metadata of each bundle.
+	packageLists string
+	sourceURLs   map[string]string
+}
+
+// buildJob is a single manifest entry that is to be built on this node.
+type buildJob struct {
+	id      string
+	builder manifest.Builder
+}
+
 // buildCmd is the action that is run when the flag -action=build is used.
 // This action will build all possible manifests, except for the ones that
-// already exist in the build cache, or fall on a different CircleCI build node.
-func buildCmd(manifestFile string, cacheDir string, pkgDir string, bundleDir string) error {
-	var (
-		cacheFile = filepath.Join(cacheDir, "cache.yml")
-		count     int
-	)
+// already exist in the build cache, or fall on a different CI build node.
+// Up to opts.jobs builds are run at the same time. Receiving SIGINT or SIGTERM
+// stops all in-flight builds.
+func buildCmd(opts buildOptions) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// buildCache is a record of all builds, that were successfully built.
-	buildCache, err := manifest.Load(cacheFile)
+	// buildManifest is a record of all possible builds.
+	buildManifest, err := manifest.Load(opts.manifestFile)
 	if err != nil {
-		return errors.Wrap(err, "failed to load build cache")
+		return errors.Wrap(err, "failed to load build manifest")
 	}
 
-	// buildManifest is a record of all possible builds.
-	buildManifest, err := manifest.Load(manifestFile)
+	opts.sourceURLs, err = loadSourceURLs(opts.packageLists)
 	if err != nil {
-		return errors.Wrap(err, "failed to load build manifest")
+		return errors.Wrap(err, "failed to load package lists")
 	}
 
-	pkgDir, err = filepath.Abs(pkgDir)
+	// buildCache is a record of all builds, that were successfully built,
+	// and are still up to date.
+	buildCache, stale, err := loadCache(opts.cacheDir, buildManifest, opts.cache)
+	if err != nil {
+		return err
+	}
+	for _, id := range stale {
+		color.Yellow("[STALE] [%s] | cached build is out of date, rebuilding\n", id)
+	}
+
+	opts.pkgDir, err = filepath.Abs(opts.pkgDir)
 	if err != nil {
 		return err
 	}
 
-	bundleDir, err = filepath.Abs(bundleDir)
+	opts.bundleDir, err = filepath.Abs(opts.bundleDir)
 	if err != nil {
 		return err
 	}
 
-	failureCount := 0
+	sharder, err := newSharder(opts.shard, buildCache)
+	if err != nil {
+		return err
+	}
+
+	color.Blue("Running on node %d of %d (from %s)\n", nodeIndex, nodeCount, nodeSource)
+
+	var (
+		report   = buildReport{NodeIndex: nodeIndex, NodeCount: nodeCount, NodeSource: nodeSource}
+		assigned = sharder.assign(buildManifest, buildCache)
+		pending  []buildJob
+	)
+
 	for _, id := range buildManifest.SortedIDs() {
+		var (
+			builder = buildManifest[id]
+			skipped = buildResult{
+				ID:        id,
+				Kind:      builder.Kind,
+				Packages:  builder.Packages,
+				NodeIndex: nodeIndex,
+			}
+		)
+
 		// Skip this build if it already exists in the cache.
-		if _, found := buildCache[id]; found {
+		if cached, found := buildCache[id]; found {
 			color.Blue("[SKIP] [%s] | build has been cached\n", id)
+			skipped.Status = statusSkippedCached
+			skipped.Bundle = cached.Bundle
+			report.Results = append(report.Results, skipped)
 			continue
 		}
 
-		// Skip this build if it does not fall on this (CircleCI) build node.
-		if count%nodeCount != nodeIndex {
-			color.Blue("[SKIP] [%s] | build run on node %d\n", id, count%nodeCount)
-			count++
+		// Skip this build if it does not fall on this build node.
+		if node := assigned[id]; node != nodeIndex {
+			color.Blue("[SKIP] [%s] | build run on node %d\n", id, node)
+			skipped.Status = statusSkippedOtherNode
+			skipped.NodeIndex = node
+			report.Results = append(report.Results, skipped)
 			continue
-		} else {
-			count++
 		}
 
+		pending = append(pending, buildJob{id, builder})
+	}
+
+	results := runJobs(pending, opts.jobs, os.Stdout, func(job buildJob, out io.Writer) buildResult {
+		return runBuild(ctx, job, opts, out)
+	})
+	report.Results = append(report.Results, results...)
+
+	report.sortResults()
+	if opts.reportFile != "" {
+		if err := report.saveJSON(opts.reportFile); err != nil {
+			return err
+		}
+	}
+	if opts.junitFile != "" {
+		if err := report.saveJUnit(opts.junitFile); err != nil {
+			return err
+		}
+	}
+
+	if ctx.Err() != nil {
+		return errors.New("build interrupted")
+	}
+	if report.count(statusFailed) > 0 {
+		return errors.New("build failures")
+	}
+	return nil
+}
+
+// runBuild builds a single manifest entry, and saves a cache fragment for it
+// if the build succeeded. All output is written to the given writer.
+func runBuild(ctx context.Context, job buildJob, opts buildOptions, out io.Writer) buildResult {
+	var (
+		id      = job.id
+		builder = job.builder
+		start   = time.Now()
+		result  = buildResult{
+			ID:        id,
+			Kind:      builder.Kind,
+			Packages:  builder.Packages,
+			NodeIndex: nodeIndex,
+		}
+	)
+
+	fail := func(reason failureReason, class failureClass, err error) buildResult {
+		color.New(color.FgRed).Fprintf(out, "[FAIL] [%s] | %v\n", id, err)
+		result.Status = statusFailed
+		result.Reason = reason
+		result.Class = class
+		result.Error = err.Error()
+		result.setDuration(time.Since(start))
+		return result
+	}
+
+	var (
+		bundle       string
+		attemptStart time.Time
+		timeouts     int
+	)
+	for attempt := 1; ; attempt++ {
+		// Don't start any new builds once the run has been interrupted.
+		if ctx.Err() != nil {
+			return fail(reasonInterrupted, "", errors.New("build interrupted before start"))
+		}
+
+		// Capture the output of this attempt, so that the failure can be
+		// classified.
 		var (
-			builder = buildManifest[id]
-			err     = build(builder, id, pkgDir, bundleDir)
+			output bytes.Buffer
+			reason failureReason
+			err    error
 		)
+		result.Attempts = attempt
+		attemptStart = time.Now()
+		bundle, reason, err = attemptBuild(ctx, job, opts, io.MultiWriter(out, &output))
+		if err == nil {
+			break
+		}
 
-		// This build failed. Report it and move along.
-		if err != nil {
-			color.Red("[FAIL] [%s] | %v\n", id, err)
-			failureCount += 1
-			continue
+		// Remove anything left behind by the failed attempt, such as a bundle
+		// that failed verification, so that it is never uploaded.
+		removeErr := os.RemoveAll(filepath.Join(opts.bundleDir, id))
+		if removeErr != nil {
+			fmt.Fprintf(out, "       Failed to remove build output: %v\n", removeErr)
 		}
 
-		bundle, err := readBundleName(bundleDir, id)
-		if err != nil {
-			color.Red("[FAIL] [%s] | %v\n", id, err)
-			failureCount += 1
-			continue
+		// This build failed. Report it and move along, unless the failure
+		// looks transient and there are retries left.
+		if reason == reasonInterrupted {
+			return fail(reason, "", err)
+		}
+		if reason == reasonTimeout {
+			timeouts++
+		}
+		class := classifyFailure(reason, err, output.Bytes())
+		if !retryAllowed(class, attempt, timeouts, opts) || removeErr != nil {
+			return fail(reason, class, err)
 		}
 
-		// This build succeeded! Save a cache fragment for this specific id.
-		color.Green("[PASS] [%s]\n", id)
-		if err := saveCacheFragment(builder, id, bundle, cacheDir); err != nil {
-			fmt.Printf("       Failed to save cache fragment: %v\n", err)
+		delay := retryDelay(opts.retryBackoff, attempt)
+		color.New(color.FgYellow).Fprintf(out, "[RETRY] [%s] | attempt %d failed, retrying in %s | %v\n", id, attempt, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return fail(reasonInterrupted, class, errors.New("build interrupted"))
 		}
 	}
 
-	if failureCount > 0 {
-		return errors.New("build failures")
+	// This build succeeded! Save a cache fragment for this specific id.
+	color.New(color.FgGreen).Fprintf(out, "[PASS] [%s]\n", id)
+	fingerprint, err := opts.cache.fingerprint(builder)
+	if err != nil {
+		fmt.Fprintf(out, "       Failed to fingerprint packer: %v\n", err)
 	}
-	return nil
+	entry := manifest.Builder{
+		Kind:            builder.Kind,
+		Packages:        builder.Packages,
+		Arch:            builder.Arch,
+		Kernel:          builder.Kernel,
+		Flavour:         builder.Flavour,
+		Bundle:          bundle,
+		NodeIndex:       nodeIndex,
+		NodeSource:      nodeSource,
+		Attempts:        result.Attempts,
+		DurationSeconds: int(time.Since(attemptStart).Round(time.Second) / time.Second),
+		Fingerprint:     fingerprint,
+	}
+	if err := saveCacheFragment(id, entry, opts.cacheDir); err != nil {
+		fmt.Fprintf(out, "       Failed to save cache fragment: %v\n", err)
+	}
+
+	result.Status = statusPassed
+	result.Bundle = bundle
+	result.setDuration(time.Since(start))
+	return result
+}
+
+// attemptBuild runs a single attempt of the given build, and returns the name
+// of the bundle that it produced. If the attempt failed, the reason for the
+// failure is returned alongside the error.
+func attemptBuild(ctx context.Context, job buildJob, opts buildOptions, out io.Writer) (string, failureReason, error) {
+	buildCtx, cancel := ctx, context.CancelFunc(func() {})
+	if opts.timeout > 0 {
+		buildCtx, cancel = context.WithTimeout(ctx, opts.timeout)
+	}
+	defer cancel()
+
+	metadata, err := bundleMetadata(job.id, job.builder, opts)
+	if err != nil {
+		return "", reasonError, errors.Wrap(err, "failed to collect bundle metadata")
+	}
+
+	if err := build(buildCtx, opts.runtime, job.builder, job.id, metadata, opts.pkgDir, opts.bundleDir, out); err != nil {
+		switch {
+		case ctx.Err() != nil:
+			return "", reasonInterrupted, errors.New("build interrupted")
+		case buildCtx.Err() == context.DeadlineExceeded:
+			return "", reasonTimeout, errors.Errorf("build timed out after %s", opts.timeout)
+		default:
+			return "", reasonError, err
+		}
+	}
+
+	bundleName, err := readBundleName(opts.bundleDir, job.id)
+	if err != nil {
+		return "", reasonError, err
+	}
+
+	var bundlePath = filepath.Join(opts.bundleDir, job.id, bundleName)
+	if err := verifyBundle(bundlePath, job.id, job.builder); err != nil {
+		return "", reasonInvalid, errors.Wrapf(err, "invalid bundle %s", bundleName)
+	}
+	return bundleName, "", nil
 }
 
 // combineCmd is the action that is run when the flag -action=combine is used.
@@ -165,18 +462,8 @@ func combineCmd(cacheDir string) error {
 // filesCmd is the action that is run when the flag -action=files is used.
 // This action combines a list of GCS bucket objects that need to be downloaded
 // for a subsequent build.
-func filesCmd(manifestFile string, cacheDir string, prefix string) error {
-	var (
-		cacheFile = filepath.Join(cacheDir, "cache.yml")
-		filesSet  = make(map[string]struct{})
-		count     int
-	)
-
-	// buildCache is a record of all builds, that were successfully built.
-	buildCache, err := manifest.Load(cacheFile)
-	if err != nil {
-		return errors.Wrap(err, "failed to load build cache")
-	}
+func filesCmd(manifestFile string, cacheDir string, prefix string, shard shardOptions, cache cacheOptions) error {
+	var filesSet = make(map[string]struct{})
 
 	// buildManifest is a record of all possible builds.
 	buildManifest, err := manifest.Load(manifestFile)
@@ -184,18 +471,22 @@ func filesCmd(manifestFile string, cacheDir string, prefix string) error {
 		return errors.Wrap(err, "failed to load build manifest")
 	}
 
-	for _, id := range buildManifest.SortedIDs() {
-		// Skip this build if it already exists in the cache.
-		if _, found := buildCache[id]; found {
-			continue
-		}
+	// buildCache is a record of all builds, that were successfully built,
+	// and are still up to date.
+	buildCache, _, err := loadCache(cacheDir, buildManifest, cache)
+	if err != nil {
+		return err
+	}
 
-		// Skip this build if it does not fall on this (CircleCI) build node.
-		if count%nodeCount != nodeIndex {
-			count++
+	sharder, err := newSharder(shard, buildCache)
+	if err != nil {
+		return err
+	}
+
+	for id, node := range sharder.assign(buildManifest, buildCache) {
+		// Skip this build if it does not fall on this build node.
+		if node != nodeIndex {
 			continue
-		} else {
-			count++
 		}
 
 		var builder = buildManifest[id]
@@ -215,64 +506,80 @@ func filesCmd(manifestFile string, cacheDir string, prefix string) error {
 	return nil
 }
 
-// build runs a repackage build for the given manifest.
-func build(builder manifest.Builder, id string, pkgDir string, bundleDir string) error {
+// build runs a repackage build for the given manifest, that embeds the given
+// metadata in the bundle. The output of the packer command is written to the
+// given writer. The packer container is killed if the context is done before
+// the build completes.
+func build(ctx context.Context, runtime command.Runtime, builder manifest.Builder, id string, metadata bundle.Metadata, pkgDir string, bundleDir string, out io.Writer) error {
 	// Check if all packages exist locally. Fail build if any of them do not.
-	packages := make([]string, len(builder.Packages))
-	for index, pkg := range builder.Packages {
-		pkg = filepath.Join(pkgDir, pkg)
-		packages[index] = pkg
-		if !exists(pkg) {
-			return errors.Errorf("package file %s does not exist", pkg)
-		}
+	packages, missing := packagePaths(builder, pkgDir)
+	if len(missing) > 0 {
+		return errors.Errorf("package file %s does not exist", missing[0])
 	}
 
+	// The output directory is created up front, as it must already exist
+	// when the packer is not run inside of a container.
 	var outputDir = filepath.Join(bundleDir, id)
-	if err := os.MkdirAll(bundleDir, 0755); err != nil {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return err
 	}
 
+	// The metadata is passed to the packer inside of the output directory, and
+	// is added to the bundle before it is compressed.
+	if err := bundle.WriteMetadata(filepath.Join(outputDir, bundle.MetadataFile), metadata); err != nil {
+		return errors.Wrap(err, "failed to write bundle metadata")
+	}
+
 	// Construct the command line to execute.
-	var cmd, args, err = command.DockerCommand(builder.Image, id, builder.Kind,
+	var cmd, args, err = runtime.Command(builder.Image, id, builder.Kind,
 		outputDir, packages)
 	if err != nil {
-		return errors.Wrap(err, "failed to construct docker command")
+		return errors.Wrap(err, "failed to construct packer command")
 	}
 
-	color.Cyan("Running command: %s %v\n", cmd, args)
-	err = command.Run(cmd, args...)
+	color.New(color.FgCyan).Fprintf(out, "Running command: %s %v\n", cmd, args)
+	err = runtime.Run(ctx, out, id, cmd, args)
+	if ctx.Err() != nil {
+		if err := runtime.Kill(id); err != nil {
+			fmt.Fprintf(out, "       %v\n", err)
+		}
+	}
 	return errors.Wrap(err, "failed to run packer command")
 }
 
+// packagePaths returns the paths of the given builder's packages inside of the
+// package directory, along with the paths of those that do not exist.
+func packagePaths(builder manifest.Builder, pkgDir string) ([]string, []string) {
+	var (
+		packages = make([]string, len(builder.Packages))
+		missing  []string
+	)
+
+	for index, pkg := range builder.Packages {
+		pkg = filepath.Join(pkgDir, pkg)
+		packages[index] = pkg
+		if !exists(pkg) {
+			missing = append(missing, pkg)
+		}
+	}
+
+	return packages, missing
+}
+
 // saveCacheFragment writes a cache fragment inside of the cache directory.
-func saveCacheFragment(builder manifest.Builder, id string, bundle string, cacheDir string) error {
+func saveCacheFragment(id string, entry manifest.Builder, cacheDir string) error {
 	var (
 		filename = filepath.Join(cacheDir, fmt.Sprintf("fragment-%s.yml", id))
 		mf       = manifest.New()
 	)
 
 	// A cache fragment contains a single entry.
-	mf.AddBuilder(manifest.Builder{builder.Kind, builder.Packages, bundle, nodeIndex, ""})
+	mf.AddBuilder(entry)
 
 	err := manifest.Save(mf, filename)
 	return errors.Wrap(err, "failed to save cache fragment")
 }
 
-// envInt looks up the given environment variable and returns its value as an
-// integer. If the variable is not set, or contains an invalid value, a
-// fallback value is returned.
-func envInt(key string, fallback int) int {
-	value, found := os.LookupEnv(key)
-	if !found {
-		return fallback
-	}
-	number, err := strconv.Atoi(value)
-	if err != nil {
-		return fallback
-	}
-	return number
-}
-
 // exists checks if the given file exists on disk.
 func exists(name string) bool {
 	if _, err := os.Stat(name); err != nil {
@@ -300,7 +607,7 @@ func readBundleName(bundleDir string, id string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	if len(files) > 1 {
+	if len(files) != 1 {
 		return "", errors.New("Unexpected number of bundles")
 	}
 	return files[0].Name(), nil
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/stackrox/kernel-packer/tools/bundle"
+	"github.com/stackrox/kernel-packer/tools/command"
+	"github.com/stackrox/kernel-packer/tools/config/manifest"
+	"github.com/stackrox/kernel-packer/tools/util"
+)
+
+// loadSourceURLs reads every package list in the given directory, and returns
+// the crawled package URLs keyed by their simplified names. A missing
+// directory results in no URLs.
+func loadSourceURLs(dir string) (map[string]string, error) {
+	var urls = make(map[string]string)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, filename := range files {
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if url := strings.TrimSpace(scanner.Text()); url != "" {
+				urls[util.SimplifyURL(url)] = url
+			}
+		}
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, errors.Wrapf(err, "failed to read package list %s", filename)
+		}
+	}
+
+	return urls, nil
+}
+
+// bundleMetadata returns the metadata to embed in the bundle of the given
+// build.
+func bundleMetadata(id string, builder manifest.Builder, opts buildOptions) (bundle.Metadata, error) {
+	var image = builder.Image
+	if image == "" {
+		image = command.DefaultImage
+	}
+
+	metadata := bundle.Metadata{
+		ID:        id,
+		Kind:      builder.Kind,
+		Arch:      builderArch(builder),
+		Image:     image,
+		BuildTime: time.Now().UTC().Truncate(time.Second),
+	}
+
+	for _, name := range builder.Packages {
+		checksum, err := checksumFile(filepath.Join(opts.pkgDir, name))
+		if err != nil {
+			return bundle.Metadata{}, err
+		}
+		metadata.Packages = append(metadata.Packages, bundle.Package{
+			Name:   name,
+			URL:    opts.sourceURLs[name],
+			SHA256: checksum,
+		})
+	}
+
+	return metadata, nil
+}
+
+// builderArch returns the kernel architecture of the given build.
+func builderArch(builder manifest.Builder) string {
+	if builder.Arch == "" {
+		return util.DefaultArch
+	}
+	return builder.Arch
+}
+
+// verifyMetadata checks that the given bundle metadata belongs to the given
+// build.
+func verifyMetadata(metadata *bundle.Metadata, id string, builder manifest.Builder) error {
+	if metadata == nil {
+		return errors.Errorf("missing %s", bundle.MetadataFile)
+	}
+	if metadata.ID != id {
+		return errors.Errorf("%s id %q does not match build id", bundle.MetadataFile, metadata.ID)
+	}
+	if metadata.Kind != builder.Kind {
+		return errors.Errorf("%s kind %q does not match build kind %q", bundle.MetadataFile, metadata.Kind, builder.Kind)
+	}
+	if metadata.Arch != "" && metadata.Arch != builderArch(builder) {
+		return errors.Errorf("%s arch %q does not match build arch %q", bundle.MetadataFile, metadata.Arch, builderArch(builder))
+	}
+
+	if len(metadata.Packages) != len(builder.Packages) {
+		return errors.Errorf("%s has %d packages, expected %d", bundle.MetadataFile, len(metadata.Packages), len(builder.Packages))
+	}
+	for index, pkg := range metadata.Packages {
+		if pkg.Name != builder.Packages[index] {
+			return errors.Errorf("%s package %q does not match build package %q", bundle.MetadataFile, pkg.Name, builder.Packages[index])
+		}
+		if pkg.SHA256 == "" {
+			return errors.Errorf("%s package %q has no checksum", bundle.MetadataFile, pkg.Name)
+		}
+	}
+
+	return nil
+}
+
+// checksumFile returns the hex encoded sha256 checksum of the given file.
+func checksumFile(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	s := sha256.New()
+	if _, err := io.Copy(s, file); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", s.Sum(nil)), nil
+}
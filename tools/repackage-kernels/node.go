@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// nodeEnv is a convention used by a CI system to tell a job which of several
+// parallel build nodes it is running on.
+type nodeEnv struct {
+	// source names the CI system.
+	source string
+
+	// indexVar and totalVar are the environment variables containing the
+	// node index and the total number of nodes.
+	indexVar string
+	totalVar string
+
+	// oneBased is set if the node index counts up from 1 instead of 0.
+	oneBased bool
+}
+
+// nodeEnvs are the conventions that are checked, in order, when the node
+// index and total are not given on the command line.
+var nodeEnvs = []nodeEnv{
+	// GitHub Actions has no such variables, so jobs export them from
+	// ${{ strategy.job-index }} and ${{ strategy.job-total }}, as the
+	// repackage workflow does.
+	{source: "github-actions", indexVar: "STRATEGY_JOB_INDEX", totalVar: "STRATEGY_JOB_TOTAL"},
+	{source: "gitlab", indexVar: "CI_NODE_INDEX", totalVar: "CI_NODE_TOTAL", oneBased: true},
+	{source: "buildkite", indexVar: "BUILDKITE_PARALLEL_JOB", totalVar: "BUILDKITE_PARALLEL_JOB_COUNT"},
+	// A generic convention, for CI systems such as OpenShift CI that have no
+	// convention of their own.
+	{source: "env", indexVar: "SHARD_INDEX", totalVar: "SHARD_TOTAL"},
+	{source: "circleci", indexVar: "CIRCLE_NODE_INDEX", totalVar: "CIRCLE_NODE_TOTAL"},
+}
+
+// detectNode determines which build node the current job is running on, and
+// how many build nodes there are. Explicit flag values take precedence over
+// the environment. A flag value of -1 means that it was not given. If nothing
+// is found, the job is assumed to be the only build node. The name of the
+// source that the values came from is returned alongside them.
+func detectNode(flagIndex int, flagTotal int) (int, int, string, error) {
+	if flagIndex >= 0 || flagTotal >= 0 {
+		if flagIndex < 0 || flagTotal < 0 {
+			return 0, 0, "", errors.New("shard-index and shard-total must be given together")
+		}
+		return checkNode(flagIndex, flagTotal, "flags")
+	}
+
+	for _, env := range nodeEnvs {
+		index, indexFound := envInt(env.indexVar)
+		total, totalFound := envInt(env.totalVar)
+		if !indexFound || !totalFound {
+			continue
+		}
+		if env.oneBased {
+			index--
+		}
+		return checkNode(index, total, env.source)
+	}
+
+	return 0, 1, "default", nil
+}
+
+// checkNode verifies that the given node index falls within the given total.
+func checkNode(index int, total int, source string) (int, int, string, error) {
+	if total < 1 || index < 0 || index >= total {
+		return 0, 0, "", errors.Errorf("invalid node index %d of %d from %s", index, total, source)
+	}
+	return index, total, source, nil
+}
+
+// envInt looks up the given environment variable and returns its value as an
+// integer. Returns false if the variable is not set, or contains an invalid
+// value.
+func envInt(key string) (int, bool) {
+	value, found := os.LookupEnv(key)
+	if !found {
+		return 0, false
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+	return number, true
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectNode(t *testing.T) {
+	tests := []struct {
+		title     string
+		env       map[string]string
+		flagIndex int
+		flagTotal int
+		index     int
+		total     int
+		source    string
+		err       string
+	}{
+		{
+			title:     "nothing set",
+			flagIndex: -1,
+			flagTotal: -1,
+			index:     0,
+			total:     1,
+			source:    "default",
+		},
+		{
+			title:     "circleci",
+			env:       map[string]string{"CIRCLE_NODE_INDEX": "2", "CIRCLE_NODE_TOTAL": "4"},
+			flagIndex: -1,
+			flagTotal: -1,
+			index:     2,
+			total:     4,
+			source:    "circleci",
+		},
+		{
+			title:     "github actions before circleci",
+			env:       map[string]string{"STRATEGY_JOB_INDEX": "1", "STRATEGY_JOB_TOTAL": "3", "CIRCLE_NODE_INDEX": "2", "CIRCLE_NODE_TOTAL": "4"},
+			flagIndex: -1,
+			flagTotal: -1,
+			index:     1,
+			total:     3,
+			source:    "github-actions",
+		},
+		{
+			title:     "gitlab is one based",
+			env:       map[string]string{"CI_NODE_INDEX": "3", "CI_NODE_TOTAL": "3"},
+			flagIndex: -1,
+			flagTotal: -1,
+			index:     2,
+			total:     3,
+			source:    "gitlab",
+		},
+		{
+			title:     "flags before environment",
+			env:       map[string]string{"CIRCLE_NODE_INDEX": "2", "CIRCLE_NODE_TOTAL": "4"},
+			flagIndex: 0,
+			flagTotal: 2,
+			index:     0,
+			total:     2,
+			source:    "flags",
+		},
+		{
+			title:     "only one flag",
+			flagIndex: 1,
+			flagTotal: -1,
+			err:       "shard-index and shard-total must be given together",
+		},
+		{
+			title:     "index out of range",
+			env:       map[string]string{"SHARD_INDEX": "4", "SHARD_TOTAL": "4"},
+			flagIndex: -1,
+			flagTotal: -1,
+			err:       "invalid node index 4 of 4 from env",
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			setNodeEnv(t, test.env)
+
+			nodeIndex, nodeTotal, source, err := detectNode(test.flagIndex, test.flagTotal)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.index, nodeIndex)
+			assert.Equal(t, test.total, nodeTotal)
+			assert.Equal(t, test.source, source)
+		})
+	}
+}
+
+// setNodeEnv replaces every node environment variable with the given values
+// for the duration of the test.
+func setNodeEnv(t *testing.T, env map[string]string) {
+	for _, nodeEnv := range nodeEnvs {
+		for _, key := range []string{nodeEnv.indexVar, nodeEnv.totalVar} {
+			if value, found := os.LookupEnv(key); found {
+				key, value := key, value
+				t.Cleanup(func() { os.Setenv(key, value) })
+			} else {
+				key := key
+				t.Cleanup(func() { os.Unsetenv(key) })
+			}
+			os.Unsetenv(key)
+		}
+	}
+
+	for key, value := range env {
+		os.Setenv(key, value)
+	}
+}
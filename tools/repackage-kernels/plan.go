@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+
+	"github.com/stackrox/kernel-packer/tools/command"
+	"github.com/stackrox/kernel-packer/tools/config/manifest"
+)
+
+// planCmd is the action that is run when the flag -action=plan is used.
+// This action loads the build manifest and build cache the same way that the
+// build action does, and prints what every build node would do, without
+// executing anything. The plan is written to the given writer.
+func planCmd(out io.Writer, manifestFile string, cacheDir string, pkgDir string, bundleDir string, runtime command.Runtime, shard shardOptions, cache cacheOptions) error {
+	// buildManifest is a record of all possible builds.
+	buildManifest, err := manifest.Load(manifestFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to load build manifest")
+	}
+
+	// buildCache is a record of all builds, that were successfully built,
+	// and are still up to date.
+	buildCache, stale, err := loadCache(cacheDir, buildManifest, cache)
+	if err != nil {
+		return err
+	}
+
+	pkgDir, err = filepath.Abs(pkgDir)
+	if err != nil {
+		return err
+	}
+
+	bundleDir, err = filepath.Abs(bundleDir)
+	if err != nil {
+		return err
+	}
+
+	sharder, err := newSharder(shard, buildCache)
+	if err != nil {
+		return err
+	}
+
+	var (
+		assigned = sharder.assign(buildManifest, buildCache)
+		ids      = buildManifest.SortedIDs()
+		cached   int
+	)
+
+	for _, id := range ids {
+		if cachedBuilder, found := buildCache[id]; found {
+			color.New(color.FgBlue).Fprintf(out, "[CACHED] [%s] | %s\n", id, cachedBuilder.Bundle)
+			cached++
+		}
+	}
+	for _, id := range stale {
+		color.New(color.FgYellow).Fprintf(out, "[STALE] [%s] | cached build is out of date\n", id)
+	}
+
+	for node := 0; node < nodeCount; node++ {
+		var (
+			builds, missingCount int
+			estimate             float64
+		)
+
+		fmt.Fprintf(out, "\nNode %d of %d:\n", node, nodeCount)
+		for _, id := range ids {
+			if assignedNode, found := assigned[id]; !found || assignedNode != node {
+				continue
+			}
+
+			var (
+				builder           = buildManifest[id]
+				packages, missing = packagePaths(builder, pkgDir)
+				image             = builder.Image
+			)
+			if image == "" {
+				image = command.DefaultImage
+			}
+
+			if len(missing) > 0 {
+				color.New(color.FgRed).Fprintf(out, "[BUILD] [%s] | kind %s, image %s\n", id, builder.Kind, image)
+			} else {
+				color.New(color.FgGreen).Fprintf(out, "[BUILD] [%s] | kind %s, image %s\n", id, builder.Kind, image)
+			}
+
+			cmd, args, err := runtime.Command(builder.Image, id, builder.Kind,
+				filepath.Join(bundleDir, id), packages)
+			if err != nil {
+				fmt.Fprintf(out, "        Failed to construct packer command: %v\n", err)
+			} else {
+				fmt.Fprintf(out, "        Command: %s %s\n", cmd, strings.Join(args, " "))
+			}
+
+			for _, pkg := range missing {
+				color.New(color.FgRed).Fprintf(out, "        Missing package: %s\n", pkg)
+			}
+
+			builds++
+			missingCount += len(missing)
+			estimate += sharder.estimate(id, builder)
+		}
+
+		fmt.Fprintf(out, "Node %d: %d builds, %d missing packages, ~%s estimated\n", node, builds, missingCount,
+			time.Duration(estimate)*time.Second)
+	}
+
+	fmt.Fprintf(out, "\nTotal: %d builds, %d cached, %d stale\n", len(assigned), cached, len(stale))
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stackrox/kernel-packer/tools/command"
+	"github.com/stackrox/kernel-packer/tools/config/manifest"
+)
+
+func TestPlanCmd(t *testing.T) {
+	defer func(count int) { nodeCount = count }(nodeCount)
+	nodeCount = 2
+	defer func(noColor bool) { color.NoColor = noColor }(color.NoColor)
+	color.NoColor = true
+
+	dir, err := ioutil.TempDir("", "plan")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var (
+		packerDir = filepath.Join(dir, "packers")
+		cacheDir  = filepath.Join(dir, "cache")
+		pkgDir    = filepath.Join(dir, "packages")
+		bundleDir = filepath.Join(dir, "bundles")
+	)
+	for _, name := range []string{packerDir, cacheDir, pkgDir} {
+		require.NoError(t, os.MkdirAll(name, 0755))
+	}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(packerDir, "entrypoint"), []byte(testEntrypoint), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(packerDir, "Dockerfile"), []byte("FROM debian:buster\n"), 0644))
+	for _, name := range []string{"kernel-devel-a.rpm", "kernel-devel-d.rpm"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(pkgDir, name), nil, 0644))
+	}
+
+	cache, err := newCacheOptions(packerDir, "")
+	require.NoError(t, err)
+	fingerprint, err := cache.fingerprint(manifest.Builder{Kind: "redhat"})
+	require.NoError(t, err)
+
+	buildManifest := manifest.Manifest{
+		"1111": {Kind: "redhat", Packages: []string{"kernel-devel-a.rpm"}},
+		"2222": {Kind: "ubuntu", Packages: []string{"linux-headers-b.deb"}},
+		"3333": {Kind: "redhat", Packages: []string{"kernel-devel-c.rpm"}},
+		"4444": {Kind: "redhat", Packages: []string{"kernel-devel-d.rpm"}, Image: "repackage:el8"},
+		"5555": {Kind: "cos"},
+	}
+	buildCache := manifest.Manifest{
+		"3333": {Kind: "redhat", Fingerprint: fingerprint, Bundle: "bundle-3333.tgz", DurationSeconds: 120},
+		"4444": {Kind: "redhat", Fingerprint: "0000", Bundle: "bundle-4444.tgz"},
+	}
+	manifestFile := filepath.Join(dir, "manifest.yml")
+	require.NoError(t, manifest.Save(buildManifest, manifestFile))
+	require.NoError(t, manifest.Save(buildCache, filepath.Join(cacheDir, "cache.yml")))
+
+	var out bytes.Buffer
+	err = planCmd(&out, manifestFile, cacheDir, pkgDir, bundleDir,
+		command.NativeRuntime{Entrypoint: "entrypoint"}, shardOptions{strategy: "round-robin"}, cache)
+	require.NoError(t, err)
+
+	// Builds are assigned to the nodes in turn, and estimated from the
+	// duration of the cached redhat build, or else from the kind weights.
+	expected := `[CACHED] [3333] | bundle-3333.tgz
+[STALE] [4444] | cached build is out of date
+
+Node 0 of 2:
+[BUILD] [1111] | kind redhat, image repackage:latest
+        Command: entrypoint 1111 redhat DIR/bundles/1111 DIR/packages/kernel-devel-a.rpm
+[BUILD] [4444] | kind redhat, image repackage:el8
+        Command: entrypoint 4444 redhat DIR/bundles/4444 DIR/packages/kernel-devel-d.rpm
+Node 0: 2 builds, 0 missing packages, ~4m0s estimated
+
+Node 1 of 2:
+[BUILD] [2222] | kind ubuntu, image repackage:latest
+        Command: entrypoint 2222 ubuntu DIR/bundles/2222 DIR/packages/linux-headers-b.deb
+        Missing package: DIR/packages/linux-headers-b.deb
+[BUILD] [5555] | kind cos, image repackage:latest
+        Failed to construct packer command: no packages given
+Node 1: 2 builds, 1 missing packages, ~11m30s estimated
+
+Total: 4 builds, 1 cached, 1 stale
+`
+	assert.Equal(t, expected, strings.ReplaceAll(out.String(), dir, "DIR"))
+}
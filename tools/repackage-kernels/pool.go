@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// runJobs runs the given jobs on the given number of workers, and returns
+// their results in the order of the jobs. With a single worker, output is
+// streamed straight to out. Otherwise, output is buffered per job and written
+// to out as one block once the job has finished, so that the output of
+// concurrent jobs is not interleaved.
+func runJobs(jobs []buildJob, workers int, out io.Writer, run func(job buildJob, out io.Writer) buildResult) []buildResult {
+	var (
+		results     = make([]buildResult, len(jobs))
+		queue       = make(chan int)
+		outputMutex sync.Mutex
+		wg          sync.WaitGroup
+	)
+
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range queue {
+				if workers == 1 {
+					results[index] = run(jobs[index], out)
+					continue
+				}
+
+				var buf bytes.Buffer
+				results[index] = run(jobs[index], &buf)
+
+				outputMutex.Lock()
+				out.Write(buf.Bytes())
+				outputMutex.Unlock()
+			}
+		}()
+	}
+
+	for index := range jobs {
+		queue <- index
+	}
+	close(queue)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunJobs(t *testing.T) {
+	tests := []struct {
+		title   string
+		jobs    int
+		workers int
+	}{
+		{
+			title:   "single worker",
+			jobs:    4,
+			workers: 1,
+		},
+		{
+			title:   "fewer workers than jobs",
+			jobs:    9,
+			workers: 3,
+		},
+		{
+			title:   "more workers than jobs",
+			jobs:    2,
+			workers: 4,
+		},
+		{
+			title:   "no jobs",
+			workers: 2,
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			var (
+				jobs        []buildJob
+				expectedIDs = []string{}
+			)
+			for job := 0; job < test.jobs; job++ {
+				id := fmt.Sprintf("%04d", test.jobs-job)
+				jobs = append(jobs, buildJob{id: id})
+				expectedIDs = append(expectedIDs, id)
+			}
+
+			// Every job waits until as many jobs are running as there are
+			// workers, or there are jobs, so that the test fails if the
+			// jobs don't run concurrently.
+			concurrent := test.workers
+			if test.jobs < concurrent {
+				concurrent = test.jobs
+			}
+			var (
+				mutex     sync.Mutex
+				running   int
+				started   int
+				maxActive int
+				release   = make(chan struct{})
+			)
+			run := func(job buildJob, out io.Writer) buildResult {
+				mutex.Lock()
+				running++
+				started++
+				if running > maxActive {
+					maxActive = running
+				}
+				if started == concurrent {
+					close(release)
+				}
+				mutex.Unlock()
+
+				fmt.Fprintf(out, "start %s\n", job.id)
+				select {
+				case <-release:
+				case <-time.After(5 * time.Second):
+				}
+				fmt.Fprintf(out, "end %s\n", job.id)
+
+				mutex.Lock()
+				running--
+				mutex.Unlock()
+				return buildResult{ID: job.id, Status: statusPassed}
+			}
+
+			var out bytes.Buffer
+			results := runJobs(jobs, test.workers, &out, run)
+
+			ids := []string{}
+			for _, result := range results {
+				ids = append(ids, result.ID)
+			}
+			assert.Equal(t, expectedIDs, ids)
+			assert.Equal(t, concurrent, maxActive)
+
+			// The output of every job is written as one block.
+			if test.jobs == 0 {
+				assert.Empty(t, out.String())
+				return
+			}
+			lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			require.Len(t, lines, 2*test.jobs)
+			for line := 0; line < len(lines); line += 2 {
+				id := strings.TrimPrefix(lines[line], "start ")
+				assert.Equal(t, "end "+id, lines[line+1])
+			}
+		})
+	}
+}
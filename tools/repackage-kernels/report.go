@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// buildStatus is the outcome of a single manifest entry in a build run.
+type buildStatus string
+
+const (
+	statusSkippedCached    buildStatus = "skipped-cached"
+	statusSkippedOtherNode buildStatus = "skipped-other-node"
+	statusPassed           buildStatus = "passed"
+	statusFailed           buildStatus = "failed"
+)
+
+// failureReason describes why a failed build did not pass.
+type failureReason string
+
+const (
+	reasonError       failureReason = "error"
+	reasonTimeout     failureReason = "timeout"
+	reasonInterrupted failureReason = "interrupted"
+	reasonInvalid     failureReason = "invalid"
+)
+
+// buildResult is the record of a single manifest entry in a build report.
+type buildResult struct {
+	ID              string        `json:"id"`
+	Kind            string        `json:"kind"`
+	Packages        []string      `json:"packages"`
+	Status          buildStatus   `json:"status"`
+	Reason          failureReason `json:"reason,omitempty"`
+	Class           failureClass  `json:"class,omitempty"`
+	Error           string        `json:"error,omitempty"`
+	Attempts        int           `json:"attempts,omitempty"`
+	DurationSeconds float64       `json:"durationSeconds"`
+	Bundle          string        `json:"bundle,omitempty"`
+	NodeIndex       int           `json:"nodeIndex"`
+}
+
+// setDuration records the given duration on the result.
+func (r *buildResult) setDuration(d time.Duration) {
+	r.DurationSeconds = d.Seconds()
+}
+
+// buildReport is a machine-readable record of every manifest entry considered
+// during a single build run.
+type buildReport struct {
+	NodeIndex  int           `json:"nodeIndex"`
+	NodeCount  int           `json:"nodeCount"`
+	NodeSource string        `json:"nodeSource"`
+	Results    []buildResult `json:"results"`
+}
+
+// sortResults orders the report results by manifest id.
+func (r *buildReport) sortResults() {
+	sort.Slice(r.Results, func(i, j int) bool {
+		return r.Results[i].ID < r.Results[j].ID
+	})
+}
+
+// count returns the number of results with the given status.
+func (r *buildReport) count(status buildStatus) int {
+	var n int
+	for _, result := range r.Results {
+		if result.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// saveJSON writes the report as indented JSON to the given filename.
+func (r *buildReport) saveJSON(filename string) error {
+	body, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal build report")
+	}
+
+	err = ioutil.WriteFile(filename, append(body, '\n'), 0644)
+	return errors.Wrap(err, "failed to write build report")
+}
+
+type junitTestSuite struct {
+	XMLName  xml.Name        `xml:"testsuite"`
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+}
+
+// saveJUnit writes the report as a JUnit XML test suite to the given
+// filename. Every manifest entry is one test case, classified by its kind.
+func (r *buildReport) saveJUnit(filename string) error {
+	var (
+		suite = junitTestSuite{
+			Name:     fmt.Sprintf("repackage-kernels-node-%d", r.NodeIndex),
+			Tests:    len(r.Results),
+			Failures: r.count(statusFailed),
+			Skipped:  r.count(statusSkippedCached) + r.count(statusSkippedOtherNode),
+		}
+		total float64
+	)
+
+	for _, result := range r.Results {
+		testCase := junitTestCase{
+			Name:      result.ID,
+			ClassName: result.Kind,
+			Time:      fmt.Sprintf("%.3f", result.DurationSeconds),
+		}
+
+		switch result.Status {
+		case statusFailed:
+			testCase.Failure = &junitMessage{Message: result.Error, Type: string(result.Reason)}
+		case statusSkippedCached, statusSkippedOtherNode:
+			testCase.Skipped = &junitMessage{Message: string(result.Status)}
+		}
+
+		total += result.DurationSeconds
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	suite.Time = fmt.Sprintf("%.3f", total)
+
+	body, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal junit report")
+	}
+
+	body = append([]byte(xml.Header), body...)
+	err = ioutil.WriteFile(filename, append(body, '\n'), 0644)
+	return errors.Wrap(err, "failed to write junit report")
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testReport returns a report with a passed, a failed, a cached and a skipped
+// result.
+func testReport() *buildReport {
+	return &buildReport{
+		NodeIndex:  1,
+		NodeCount:  2,
+		NodeSource: "flag",
+		Results: []buildResult{
+			{
+				ID:              "1111",
+				Kind:            "redhat",
+				Packages:        []string{"kernel-devel-4.18.0-305.el8.x86_64.rpm"},
+				Status:          statusPassed,
+				Attempts:        1,
+				DurationSeconds: 1.5,
+				Bundle:          "bundle-4.18.0-305.el8.x86_64.tgz",
+				NodeIndex:       1,
+			},
+			{
+				ID:              "2222",
+				Kind:            "ubuntu",
+				Packages:        []string{"linux-headers-5.4.0-1048-gke_5.4.0-1048.50_amd64.deb"},
+				Status:          statusFailed,
+				Reason:          reasonTimeout,
+				Error:           "timed out",
+				Attempts:        2,
+				DurationSeconds: 2.25,
+				NodeIndex:       1,
+			},
+			{
+				ID:        "3333",
+				Kind:      "redhat",
+				Status:    statusSkippedCached,
+				NodeIndex: 1,
+			},
+			{
+				ID:        "4444",
+				Kind:      "cos",
+				Status:    statusSkippedOtherNode,
+				NodeIndex: 0,
+			},
+		},
+	}
+}
+
+func TestBuildReportCount(t *testing.T) {
+	tests := []struct {
+		title    string
+		status   buildStatus
+		expected int
+	}{
+		{
+			title:    "passed",
+			status:   statusPassed,
+			expected: 1,
+		},
+		{
+			title:    "failed",
+			status:   statusFailed,
+			expected: 1,
+		},
+		{
+			title:    "cached",
+			status:   statusSkippedCached,
+			expected: 1,
+		},
+		{
+			title:    "other node",
+			status:   statusSkippedOtherNode,
+			expected: 1,
+		},
+		{
+			title:  "unknown",
+			status: buildStatus("unknown"),
+		},
+	}
+
+	report := testReport()
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, report.count(test.status))
+		})
+	}
+
+	assert.Equal(t, 0, (&buildReport{}).count(statusPassed))
+}
+
+func TestBuildReportSaveJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "report.json")
+	require.NoError(t, testReport().saveJSON(filename))
+
+	body, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"nodeIndex": 1,
+		"nodeCount": 2,
+		"nodeSource": "flag",
+		"results": [
+			{
+				"id": "1111",
+				"kind": "redhat",
+				"packages": ["kernel-devel-4.18.0-305.el8.x86_64.rpm"],
+				"status": "passed",
+				"attempts": 1,
+				"durationSeconds": 1.5,
+				"bundle": "bundle-4.18.0-305.el8.x86_64.tgz",
+				"nodeIndex": 1
+			},
+			{
+				"id": "2222",
+				"kind": "ubuntu",
+				"packages": ["linux-headers-5.4.0-1048-gke_5.4.0-1048.50_amd64.deb"],
+				"status": "failed",
+				"reason": "timeout",
+				"error": "timed out",
+				"attempts": 2,
+				"durationSeconds": 2.25,
+				"nodeIndex": 1
+			},
+			{
+				"id": "3333",
+				"kind": "redhat",
+				"packages": null,
+				"status": "skipped-cached",
+				"durationSeconds": 0,
+				"nodeIndex": 1
+			},
+			{
+				"id": "4444",
+				"kind": "cos",
+				"packages": null,
+				"status": "skipped-other-node",
+				"durationSeconds": 0,
+				"nodeIndex": 0
+			}
+		]
+	}`, string(body))
+	assert.Equal(t, byte('\n'), body[len(body)-1])
+}
+
+func TestBuildReportSaveJUnit(t *testing.T) {
+	tests := []struct {
+		title    string
+		report   *buildReport
+		tests    int
+		failures int
+		skipped  int
+		time     string
+		cases    []junitTestCase
+	}{
+		{
+			title:  "empty",
+			report: &buildReport{},
+			time:   "0.000",
+		},
+		{
+			title: "only skipped",
+			report: &buildReport{Results: []buildResult{
+				{ID: "3333", Kind: "redhat", Status: statusSkippedCached},
+				{ID: "4444", Kind: "cos", Status: statusSkippedOtherNode},
+			}},
+			tests:   2,
+			skipped: 2,
+			time:    "0.000",
+			cases: []junitTestCase{
+				{Name: "3333", ClassName: "redhat", Time: "0.000", Skipped: &junitMessage{Message: "skipped-cached"}},
+				{Name: "4444", ClassName: "cos", Time: "0.000", Skipped: &junitMessage{Message: "skipped-other-node"}},
+			},
+		},
+		{
+			title:    "mixed",
+			report:   testReport(),
+			tests:    4,
+			failures: 1,
+			skipped:  2,
+			time:     "3.750",
+			cases: []junitTestCase{
+				{Name: "1111", ClassName: "redhat", Time: "1.500"},
+				{Name: "2222", ClassName: "ubuntu", Time: "2.250", Failure: &junitMessage{Message: "timed out", Type: "timeout"}},
+				{Name: "3333", ClassName: "redhat", Time: "0.000", Skipped: &junitMessage{Message: "skipped-cached"}},
+				{Name: "4444", ClassName: "cos", Time: "0.000", Skipped: &junitMessage{Message: "skipped-other-node"}},
+			},
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "report")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(dir, fmt.Sprintf("junit-%d.xml", index))
+			require.NoError(t, test.report.saveJUnit(filename))
+
+			body, err := ioutil.ReadFile(filename)
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(string(body), xml.Header))
+
+			var suite junitTestSuite
+			require.NoError(t, xml.Unmarshal(body, &suite))
+			assert.Equal(t, fmt.Sprintf("repackage-kernels-node-%d", test.report.NodeIndex), suite.Name)
+			assert.Equal(t, test.tests, suite.Tests)
+			assert.Equal(t, test.failures, suite.Failures)
+			assert.Equal(t, test.skipped, suite.Skipped)
+			assert.Equal(t, test.time, suite.Time)
+			assert.Equal(t, test.cases, suite.Cases)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os/exec"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// failureClass labels a build failure as either worth retrying or not.
+type failureClass string
+
+const (
+	// classTransient failures are caused by the build host, and may succeed
+	// when retried.
+	classTransient failureClass = "transient"
+
+	// classPermanent failures are deterministic, and will fail again when
+	// retried.
+	classPermanent failureClass = "permanent"
+)
+
+var (
+	// transientExitCodes are docker exit codes that indicate a problem with
+	// the build host rather than with the packages being built.
+	transientExitCodes = map[int]struct{}{
+		// The docker daemon failed to run the container.
+		125: {},
+		// The container was killed, typically by the OOM killer.
+		137: {},
+	}
+
+	// transientOutputRegex matches build output that indicates a problem with
+	// the build host rather than with the packages being built.
+	transientOutputRegex = regexp.MustCompile(`(?i)` +
+		`no space left on device` +
+		`|cannot find an unused loop device` +
+		`|failed to set ?up loop device` +
+		`|no free loop devices` +
+		`|device or resource busy` +
+		`|resource temporarily unavailable` +
+		`|cannot connect to the docker daemon` +
+		`|error response from daemon` +
+		`|connection reset by peer` +
+		`|i/o timeout`)
+)
+
+// classifyFailure inspects the reason, error and captured output of a failed
+// build, and decides whether the failure is transient or permanent. Only the
+// output of builds that failed to run is inspected, as a bundle that fails
+// verification fails again when rebuilt, whatever its build output says.
+func classifyFailure(reason failureReason, err error, output []byte) failureClass {
+	switch reason {
+	case reasonTimeout:
+		// A build may time out on a busy build host, so it is retried, but only
+		// as often as retryAllowed permits, as it may hang every time instead.
+		return classTransient
+	case reasonError:
+	default:
+		return classPermanent
+	}
+
+	if exitErr, ok := errors.Cause(err).(*exec.ExitError); ok {
+		if _, found := transientExitCodes[exitErr.ExitCode()]; found {
+			return classTransient
+		}
+	}
+
+	if transientOutputRegex.Match(output) {
+		return classTransient
+	}
+
+	return classPermanent
+}
+
+// retryAllowed reports whether a build may be retried after the given number
+// of failed attempts, of which the given number timed out, when the last one
+// failed with the given class.
+func retryAllowed(class failureClass, attempts int, timeouts int, opts buildOptions) bool {
+	if class != classTransient || attempts > opts.retries {
+		return false
+	}
+	return timeouts <= opts.timeoutRetry
+}
+
+// retryDelay returns how long to wait before retrying a build after the given
+// (1-based) failed attempt. The delay doubles with every attempt.
+func retryDelay(backoff time.Duration, attempt int) time.Duration {
+	return backoff << uint(attempt-1)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	exitErr := func(code int) error {
+		err := exec.Command("sh", "-c", fmt.Sprintf("exit %d", code)).Run()
+		return errors.Wrap(err, "failed to run packer command")
+	}
+
+	tests := []struct {
+		title    string
+		reason   failureReason
+		err      error
+		output   string
+		expected failureClass
+	}{
+		{
+			title:    "timeout",
+			reason:   reasonTimeout,
+			err:      errors.New("build timed out after 1h0m0s"),
+			expected: classTransient,
+		},
+		{
+			title:    "docker daemon error",
+			reason:   reasonError,
+			err:      exitErr(125),
+			expected: classTransient,
+		},
+		{
+			title:    "container killed",
+			reason:   reasonError,
+			err:      exitErr(137),
+			expected: classTransient,
+		},
+		{
+			title:    "loop device exhaustion",
+			reason:   reasonError,
+			err:      exitErr(1),
+			output:   "Mounting loop device partition\nkpartx: failed to setup loop device for image\n",
+			expected: classTransient,
+		},
+		{
+			title:    "out of disk",
+			reason:   reasonError,
+			err:      exitErr(2),
+			output:   "tar: ./usr/src/linux-headers: Cannot write: No space left on device\n",
+			expected: classTransient,
+		},
+		{
+			title:    "packaging bug",
+			reason:   reasonError,
+			err:      exitErr(1),
+			output:   "blank kernel dir\n",
+			expected: classPermanent,
+		},
+		{
+			title:    "missing package",
+			reason:   reasonError,
+			err:      errors.New("package file /packages/foo.rpm does not exist"),
+			expected: classPermanent,
+		},
+		{
+			title:    "invalid bundle",
+			reason:   reasonInvalid,
+			err:      errors.New(`invalid bundle bundle-4.18.0-305.el8.x86_64.tgz: BUNDLE_CHECKSUM "other" does not match build id`),
+			output:   "curl: (56) Recv failure: Connection reset by peer\n",
+			expected: classPermanent,
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			actual := classifyFailure(test.reason, test.err, []byte(test.output))
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestRetryAllowed(t *testing.T) {
+	var opts = buildOptions{retries: 3, timeoutRetry: 1}
+
+	tests := []struct {
+		title    string
+		class    failureClass
+		attempts int
+		timeouts int
+		expected bool
+	}{
+		{
+			title:    "transient",
+			class:    classTransient,
+			attempts: 1,
+			expected: true,
+		},
+		{
+			title:    "permanent",
+			class:    classPermanent,
+			attempts: 1,
+		},
+		{
+			title:    "out of retries",
+			class:    classTransient,
+			attempts: 4,
+		},
+		{
+			title:    "first timeout",
+			class:    classTransient,
+			attempts: 2,
+			timeouts: 1,
+			expected: true,
+		},
+		{
+			title:    "too many timeouts",
+			class:    classTransient,
+			attempts: 2,
+			timeouts: 2,
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, retryAllowed(test.class, test.attempts, test.timeouts, opts))
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, retryDelay(30*time.Second, 1))
+	assert.Equal(t, 60*time.Second, retryDelay(30*time.Second, 2))
+	assert.Equal(t, 120*time.Second, retryDelay(30*time.Second, 3))
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/stackrox/kernel-packer/tools/config/manifest"
+)
+
+// kindWeights are rough estimates of how long a single build of each kind
+// takes, in seconds. They are only used when there is no build history for a
+// given kind.
+var kindWeights = map[string]float64{
+	"cos":           600,
+	"coreos":        180,
+	"debian":        90,
+	"dockerdesktop": 600,
+	"garden":        120,
+	"linuxkit":      600,
+	"minikube":      900,
+	"oracle":        60,
+	"redhat":        60,
+	"suse":          90,
+	"ubuntu":        90,
+}
+
+// defaultWeight is the estimated duration, in seconds, of a build of a kind
+// that is missing from kindWeights.
+const defaultWeight = 60
+
+// shardOptions selects how builds are split across build nodes. The files and
+// build actions must be given the same options, so that every node downloads
+// the packages for exactly the builds that it runs.
+type shardOptions struct {
+	// strategy is either "balanced" or "round-robin".
+	strategy string
+
+	// historyGlob matches JSON build reports from previous runs, whose
+	// durations are used to estimate the duration of each build.
+	historyGlob string
+}
+
+// sharder assigns builds to build nodes.
+type sharder struct {
+	strategy string
+
+	// durations are the durations of previous builds, by manifest id.
+	durations map[string]float64
+
+	// kindDurations are the average durations of previous builds, by kind.
+	kindDurations map[string]float64
+}
+
+// newSharder creates a sharder for the given options. Build durations are
+// collected from the build cache, and from any previous build reports.
+func newSharder(opts shardOptions, buildCache manifest.Manifest) (*sharder, error) {
+	if opts.strategy != "balanced" && opts.strategy != "round-robin" {
+		return nil, errors.Errorf("unknown sharding strategy %q", opts.strategy)
+	}
+
+	var (
+		s = &sharder{
+			strategy:      opts.strategy,
+			durations:     make(map[string]float64),
+			kindDurations: make(map[string]float64),
+		}
+		kindTotals = make(map[string]float64)
+		kindCounts = make(map[string]int)
+	)
+
+	record := func(id string, kind string, seconds float64) {
+		s.durations[id] = seconds
+		kindTotals[kind] += seconds
+		kindCounts[kind]++
+	}
+
+	for _, id := range buildCache.SortedIDs() {
+		if builder := buildCache[id]; builder.DurationSeconds > 0 {
+			record(id, builder.Kind, float64(builder.DurationSeconds))
+		}
+	}
+
+	if opts.historyGlob != "" {
+		filenames, err := filepath.Glob(opts.historyGlob)
+		if err != nil {
+			return nil, errors.Wrap(err, "bad history glob pattern")
+		}
+		sort.Strings(filenames)
+
+		for _, filename := range filenames {
+			report, err := loadReport(filename)
+			if err != nil {
+				return nil, err
+			}
+			for _, result := range report.Results {
+				if result.Status == statusPassed && result.DurationSeconds > 0 {
+					record(result.ID, result.Kind, result.DurationSeconds)
+				}
+			}
+		}
+	}
+
+	for kind, total := range kindTotals {
+		s.kindDurations[kind] = total / float64(kindCounts[kind])
+	}
+
+	return s, nil
+}
+
+// estimate returns the expected duration, in seconds, of the given build.
+func (s *sharder) estimate(id string, builder manifest.Builder) float64 {
+	if seconds, found := s.durations[id]; found {
+		return seconds
+	}
+	if seconds, found := s.kindDurations[builder.Kind]; found {
+		return seconds
+	}
+	if seconds, found := kindWeights[builder.Kind]; found {
+		return seconds
+	}
+	return defaultWeight
+}
+
+// assign decides which build node every build that is not already in the
+// build cache will run on. The returned map contains the node index for each
+// of those manifest ids. The assignment only depends on the manifest, the
+// cache and the build history, so it is the same on every build node.
+func (s *sharder) assign(buildManifest manifest.Manifest, buildCache manifest.Manifest) map[string]int {
+	var ids []string
+	for _, id := range buildManifest.SortedIDs() {
+		if _, found := buildCache[id]; !found {
+			ids = append(ids, id)
+		}
+	}
+
+	if s.strategy == "round-robin" {
+		assigned := make(map[string]int, len(ids))
+		for count, id := range ids {
+			assigned[id] = count % nodeCount
+		}
+		return assigned
+	}
+
+	return balance(ids, func(id string) float64 {
+		return s.estimate(id, buildManifest[id])
+	}, nodeCount)
+}
+
+// balance assigns the given ids across the given number of nodes, so that
+// the total weight on each node is as even as possible. The heaviest ids are
+// placed first, each onto the node with the least total weight so far. Ties
+// are broken by id and by node index, so that the result is deterministic.
+func balance(ids []string, weight func(id string) float64, nodes int) map[string]int {
+	var (
+		sorted   = append([]string{}, ids...)
+		weights  = make(map[string]float64, len(ids))
+		loads    = make([]float64, nodes)
+		assigned = make(map[string]int, len(ids))
+	)
+
+	for _, id := range ids {
+		weights[id] = weight(id)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if weights[sorted[i]] != weights[sorted[j]] {
+			return weights[sorted[i]] > weights[sorted[j]]
+		}
+		return sorted[i] < sorted[j]
+	})
+
+	for _, id := range sorted {
+		node := 0
+		for candidate := 1; candidate < nodes; candidate++ {
+			if loads[candidate] < loads[node] {
+				node = candidate
+			}
+		}
+		assigned[id] = node
+		loads[node] += weights[id]
+	}
+
+	return assigned
+}
+
+// loadReport reads a JSON build report from the given filename.
+func loadReport(filename string) (*buildReport, error) {
+	body, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read build report")
+	}
+
+	var report buildReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse build report %s", filename)
+	}
+
+	return &report, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stackrox/kernel-packer/tools/config/manifest"
+)
+
+func TestBalance(t *testing.T) {
+	weights := map[string]float64{
+		"a": 900,
+		"b": 60,
+		"c": 60,
+		"d": 600,
+		"e": 60,
+		"f": 60,
+	}
+	ids := []string{"a", "b", "c", "d", "e", "f"}
+
+	assigned := balance(ids, func(id string) float64 { return weights[id] }, 2)
+
+	loads := make([]float64, 2)
+	for id, node := range assigned {
+		loads[node] += weights[id]
+	}
+
+	assert.Equal(t, map[string]int{"a": 0, "d": 1, "b": 1, "c": 1, "e": 1, "f": 1}, assigned)
+	assert.Equal(t, []float64{900, 840}, loads)
+
+	// The assignment must not depend on the order of the given ids.
+	reversed := []string{"f", "e", "d", "c", "b", "a"}
+	assert.Equal(t, assigned, balance(reversed, func(id string) float64 { return weights[id] }, 2))
+}
+
+func TestSharderAssign(t *testing.T) {
+	defer func(count int) { nodeCount = count }(nodeCount)
+	nodeCount = 2
+
+	buildManifest := manifest.Manifest{
+		"a": {Kind: "minikube"},
+		"b": {Kind: "redhat"},
+		"c": {Kind: "redhat"},
+		"d": {Kind: "redhat"},
+		"e": {Kind: "cos"},
+	}
+	buildCache := manifest.Manifest{
+		"b":  {Kind: "redhat", DurationSeconds: 200},
+		"zz": {Kind: "cos", DurationSeconds: 100},
+	}
+
+	roundRobin, err := newSharder(shardOptions{strategy: "round-robin"}, buildCache)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 0, "c": 1, "d": 0, "e": 1}, roundRobin.assign(buildManifest, buildCache))
+
+	balanced, err := newSharder(shardOptions{strategy: "balanced"}, buildCache)
+	require.NoError(t, err)
+
+	// Durations of cached builds are averaged per kind, and other kinds
+	// fall back to their static weights.
+	assert.Equal(t, float64(200), balanced.estimate("c", buildManifest["c"]))
+	assert.Equal(t, float64(100), balanced.estimate("e", buildManifest["e"]))
+	assert.Equal(t, float64(900), balanced.estimate("a", buildManifest["a"]))
+	assert.Equal(t, map[string]int{"a": 0, "c": 1, "d": 1, "e": 1}, balanced.assign(buildManifest, buildCache))
+
+	_, err = newSharder(shardOptions{strategy: "random"}, buildCache)
+	assert.EqualError(t, err, `unknown sharding strategy "random"`)
+}
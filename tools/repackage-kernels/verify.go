@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/stackrox/kernel-packer/tools/bundle"
+	"github.com/stackrox/kernel-packer/tools/config/manifest"
+)
+
+// verifyBundle reads the given bundle archive in full, and checks that it was
+// built correctly for the given build. The archive must not be truncated, must
+// be valid, must have been built for the given id, must have a BUNDLE_UNAME
+// that matches the kernel version of the packages, and must hold metadata for
+// the given build.
+func verifyBundle(filename string, id string, builder manifest.Builder) error {
+	b, err := bundle.Open(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := b.Validate(); err != nil {
+		return err
+	}
+
+	if b.Meta.Checksum != id {
+		return errors.Errorf("BUNDLE_CHECKSUM %q does not match build id", b.Meta.Checksum)
+	}
+
+	if kernel := builderKernel(builder); kernel != "" && !unameMatches(b.Meta.Uname, kernel) {
+		return errors.Errorf("BUNDLE_UNAME %q does not match package kernel %q", b.Meta.Uname, kernel)
+	}
+
+	return verifyMetadata(b.Metadata, id, builder)
+}
+
+// builderKernel returns the kernel release that the given build is for, as
+// recorded in the manifest or else as named by its packages. Packages such as
+// COS and Flatcar images name no kernel, in which case nothing is returned.
+func builderKernel(builder manifest.Builder) string {
+	if builder.Kernel != "" {
+		return builder.Kernel
+	}
+	return manifest.NewBuilder(builder.Kind, builder.Arch, builder.Packages).Kernel
+}
+
+// unameMatches reports whether the given uname is for the given kernel. A
+// kernel built from sources may append a local version to the upstream
+// version, such as the "-linuxkit" of "4.19.76-linuxkit".
+func unameMatches(uname string, kernel string) bool {
+	return uname == kernel || strings.HasPrefix(uname, kernel+"-")
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stackrox/kernel-packer/tools/bundle"
+	"github.com/stackrox/kernel-packer/tools/bundle/bundletest"
+	"github.com/stackrox/kernel-packer/tools/config/manifest"
+)
+
+func TestVerifyBundle(t *testing.T) {
+	redhatFiles := func() map[string]string {
+		return map[string]string{
+			"./BUNDLE_CHECKSUM":  "sha",
+			"./BUNDLE_DISTRO":    "redhat",
+			"./BUNDLE_UNAME":     "4.18.0-305.el8.x86_64",
+			"./BUNDLE_VERSION":   "4",
+			"./BUNDLE_MAJOR":     "18",
+			"./BUNDLE_MINOR":     "0",
+			"./BUNDLE_BUILD_DIR": ".",
+			"./.config":          "CONFIG_BPF=y\n",
+			"./Makefile":         "VERSION = 4\n",
+		}
+	}
+	redhat := manifest.Builder{
+		Kind:     "redhat",
+		Packages: []string{"kernel-devel-4.18.0-305.el8.x86_64.rpm"},
+	}
+
+	tests := []struct {
+		title   string
+		files   map[string]string
+		builder manifest.Builder
+		change  func(metadata *bundle.Metadata)
+		err     string
+	}{
+		{
+			title:   "valid",
+			files:   redhatFiles(),
+			builder: redhat,
+		},
+		{
+			title: "valid with build dir",
+			files: map[string]string{
+				"./BUNDLE_CHECKSUM":  "sha",
+				"./BUNDLE_DISTRO":    "debian",
+				"./BUNDLE_UNAME":     "5.10.0-28-cloud-amd64",
+				"./BUNDLE_VERSION":   "5",
+				"./BUNDLE_MAJOR":     "10",
+				"./BUNDLE_MINOR":     "0",
+				"./BUNDLE_BUILD_DIR": "./usr/src/linux-headers-5.10.0-28-cloud-amd64",
+				"./usr/src/linux-headers-5.10.0-28-cloud-amd64/.config":  "",
+				"./usr/src/linux-headers-5.10.0-28-cloud-amd64/Makefile": "",
+			},
+			builder: manifest.Builder{
+				Kind: "debian",
+				Packages: []string{
+					"linux-kbuild-5.10_5.10.218-1_amd64.deb",
+					"linux-headers-5.10.0-28-cloud-amd64_5.10.209-2_amd64.deb",
+				},
+			},
+		},
+		{
+			title: "no version in package names",
+			files: redhatFiles(),
+			builder: manifest.Builder{
+				Kind:     "cos",
+				Packages: []string{"cos-tools-12739.68.0-kernel-src.tar.gz"},
+			},
+		},
+		{
+			title: "checksum mismatch",
+			files: func() map[string]string {
+				files := redhatFiles()
+				files["./BUNDLE_CHECKSUM"] = "other"
+				return files
+			}(),
+			builder: redhat,
+			err:     `BUNDLE_CHECKSUM "other" does not match build id`,
+		},
+		{
+			title: "uname mismatch",
+			files: redhatFiles(),
+			builder: manifest.Builder{
+				Kind:     "redhat",
+				Packages: []string{"kernel-devel-4.18.0-348.el8.x86_64.rpm"},
+			},
+			err: `BUNDLE_UNAME "4.18.0-305.el8.x86_64" does not match package kernel "4.18.0-348.el8.x86_64"`,
+		},
+		{
+			title: "recorded kernel",
+			files: redhatFiles(),
+			builder: manifest.Builder{
+				Kind:     "redhat",
+				Packages: []string{"kernel-devel-4.18.0-305.el8.x86_64.rpm"},
+				Kernel:   "4.18.0-348.el8.x86_64",
+			},
+			err: `BUNDLE_UNAME "4.18.0-305.el8.x86_64" does not match package kernel "4.18.0-348.el8.x86_64"`,
+		},
+		{
+			title: "local version of source kernel",
+			files: func() map[string]string {
+				files := redhatFiles()
+				files["./BUNDLE_DISTRO"] = "linuxkit"
+				files["./BUNDLE_UNAME"] = "4.19.76-linuxkit"
+				files["./BUNDLE_MAJOR"] = "19"
+				files["./BUNDLE_MINOR"] = "76"
+				return files
+			}(),
+			builder: manifest.Builder{
+				Kind:     "linuxkit",
+				Packages: []string{"https://github.com/linuxkit/linux/archive/v4.19.76.tar.gz"},
+			},
+		},
+		{
+			title:   "metadata kind mismatch",
+			files:   redhatFiles(),
+			builder: redhat,
+			change:  func(metadata *bundle.Metadata) { metadata.Kind = "oracle" },
+			err:     `bundle.json kind "oracle" does not match build kind "redhat"`,
+		},
+		{
+			title:   "metadata arch mismatch",
+			files:   redhatFiles(),
+			builder: redhat,
+			change:  func(metadata *bundle.Metadata) { metadata.Arch = "aarch64" },
+			err:     `bundle.json arch "aarch64" does not match build arch "x86_64"`,
+		},
+		{
+			title:   "metadata package mismatch",
+			files:   redhatFiles(),
+			builder: redhat,
+			change: func(metadata *bundle.Metadata) {
+				metadata.Packages[0].Name = "kernel-devel-4.18.0-305.el8.aarch64.rpm"
+			},
+			err: `bundle.json package "kernel-devel-4.18.0-305.el8.aarch64.rpm" does not match build package "kernel-devel-4.18.0-305.el8.x86_64.rpm"`,
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "verify")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			metadata := bundle.Metadata{
+				ID:    test.files["./BUNDLE_CHECKSUM"],
+				Kind:  test.builder.Kind,
+				Uname: test.files["./BUNDLE_UNAME"],
+			}
+			for _, name := range test.builder.Packages {
+				metadata.Packages = append(metadata.Packages, bundle.Package{Name: name, SHA256: "0000"})
+			}
+			if test.change != nil {
+				test.change(&metadata)
+			}
+			metadataBody, err := json.Marshal(metadata)
+			require.NoError(t, err)
+			test.files["./"+bundle.MetadataFile] = string(metadataBody)
+
+			body := bundletest.Archive(t, test.files)
+			filename := filepath.Join(dir, fmt.Sprintf("bundle-%d.tgz", index))
+			require.NoError(t, ioutil.WriteFile(filename, body, 0644))
+
+			err = verifyBundle(filename, "sha", test.builder)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
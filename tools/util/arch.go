@@ -0,0 +1,93 @@
+package util
+
+import (
+	"regexp"
+)
+
+// DefaultArch is the kernel architecture of packages that do not name one, and
+// of builders that have no arch.
+const DefaultArch = "x86_64"
+
+var (
+	// archAliases maps the architecture names used by package formats, such
+	// as "amd64" for Debian packages, to kernel architecture names.
+	archAliases = map[string]string{
+		"x86_64":  "x86_64",
+		"amd64":   "x86_64",
+		"aarch64": "aarch64",
+		"arm64":   "aarch64",
+	}
+
+	// packageArchRegex matches the architecture of RPM and Debian packages,
+	// such as "kernel-devel-4.18.0-305.el8.x86_64.rpm" or
+	// "linux-headers-5.4.0-1048-gke_5.4.0-1048.50_amd64.deb".
+	packageArchRegex = regexp.MustCompile(`[._](x86_64|[a-z0-9]+)\.(?:rpm|deb)$`)
+)
+
+// NormalizeArch returns the kernel architecture name of the given package
+// format architecture name, such that "arm64" becomes "aarch64". False is
+// returned for unknown architectures.
+func NormalizeArch(arch string) (string, bool) {
+	normalized, found := archAliases[arch]
+	return normalized, found
+}
+
+// PackageArch returns the kernel architecture of the given package URL or
+// simplified package name, or an empty string if the package is architecture
+// independent, such as "noarch" and "all" packages, or does not name an
+// architecture.
+func PackageArch(pkg string) string {
+	matches := packageArchRegex.FindStringSubmatch(pkg)
+	if matches == nil {
+		return ""
+	}
+	return archAliases[matches[1]]
+}
+
+// PackagesArch returns the kernel architecture of the first of the given
+// packages that names one, or an empty string if none do.
+func PackagesArch(packages []string) string {
+	for _, pkg := range packages {
+		if arch := PackageArch(pkg); arch != "" {
+			return arch
+		}
+	}
+	return ""
+}
+
+// SplitArch splits the given packages into a group for every architecture
+// that they name, in order of appearance. Architecture independent packages
+// are added to every group. A single group of all packages is returned if
+// at most one architecture is named.
+func SplitArch(packages []string) [][]string {
+	var arches []string
+	for _, pkg := range packages {
+		if arch := PackageArch(pkg); arch != "" && !containsString(arches, arch) {
+			arches = append(arches, arch)
+		}
+	}
+	if len(arches) <= 1 {
+		return [][]string{packages}
+	}
+
+	groups := make([][]string, 0, len(arches))
+	for _, arch := range arches {
+		var group []string
+		for _, pkg := range packages {
+			if pkgArch := PackageArch(pkg); pkgArch == "" || pkgArch == arch {
+				group = append(group, pkg)
+			}
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
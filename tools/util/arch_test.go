@@ -0,0 +1,94 @@
+package util
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackageArch(t *testing.T) {
+	tests := []struct {
+		title    string
+		input    string
+		expected string
+	}{
+		{
+			title:    "rpm",
+			input:    "https://mirrors.kernel.org/centos/8/BaseOS/x86_64/os/Packages/kernel-devel-4.18.0-305.el8.x86_64.rpm",
+			expected: "x86_64",
+		},
+		{
+			title:    "aarch64 rpm",
+			input:    "https://mirrors.kernel.org/centos/8/BaseOS/aarch64/os/Packages/kernel-devel-4.18.0-305.el8.aarch64.rpm",
+			expected: "aarch64",
+		},
+		{
+			title: "noarch rpm",
+			input: "https://updates.suse.com/SUSE/Updates/SLE-SERVER/12-SP5/x86_64/update/noarch/kernel-devel-4.12.14-122.103.1.noarch.rpm",
+		},
+		{
+			title:    "amd64 deb",
+			input:    "http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-headers-5.4.0-1048-gke_5.4.0-1048.50_amd64.deb",
+			expected: "x86_64",
+		},
+		{
+			title:    "simplified arm64 deb",
+			input:    "http---ports.ubuntu.com-ubuntu-ports-pool-main-l-linux-aws-linux-headers-5.4.0-1048-aws_5.4.0-1048.50_arm64.deb",
+			expected: "aarch64",
+		},
+		{
+			title: "all deb",
+			input: "http://security.ubuntu.com/ubuntu/pool/main/l/linux-gke/linux-gke-headers-5.4.0-1048_5.4.0-1048.50_all.deb",
+		},
+		{
+			title: "tarball",
+			input: "https://storage.googleapis.com/cos-tools/12739.68.0/kernel-headers.tgz",
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, PackageArch(test.input))
+		})
+	}
+}
+
+func TestSplitArch(t *testing.T) {
+	tests := []struct {
+		title    string
+		input    []string
+		expected [][]string
+	}{
+		{
+			title:    "single arch",
+			input:    []string{"linux-headers-5.4.0-1048-gke_5.4.0-1048.50_amd64.deb", "linux-gke-headers-5.4.0-1048_5.4.0-1048.50_all.deb"},
+			expected: [][]string{{"linux-headers-5.4.0-1048-gke_5.4.0-1048.50_amd64.deb", "linux-gke-headers-5.4.0-1048_5.4.0-1048.50_all.deb"}},
+		},
+		{
+			title:    "no arch",
+			input:    []string{"kernel-src.tar.gz", "kernel-headers.tgz"},
+			expected: [][]string{{"kernel-src.tar.gz", "kernel-headers.tgz"}},
+		},
+		{
+			title: "two arches",
+			input: []string{
+				"kernel-devel-5.3.18-150300.59.43.1.noarch.rpm",
+				"kernel-default-devel-5.3.18-150300.59.43.1.x86_64.rpm",
+				"kernel-default-devel-5.3.18-150300.59.43.1.aarch64.rpm",
+			},
+			expected: [][]string{
+				{"kernel-devel-5.3.18-150300.59.43.1.noarch.rpm", "kernel-default-devel-5.3.18-150300.59.43.1.x86_64.rpm"},
+				{"kernel-devel-5.3.18-150300.59.43.1.noarch.rpm", "kernel-default-devel-5.3.18-150300.59.43.1.aarch64.rpm"},
+			},
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, SplitArch(test.input))
+		})
+	}
+}
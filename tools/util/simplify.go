@@ -1,5 +1,14 @@
 package util
 
+import "regexp"
+
+var httpsPrefixRegex = regexp.MustCompile(`.*https\:`)
+
+// NormalizeURL removes anything listed before an https URL.
+func NormalizeURL(url string) string {
+	return httpsPrefixRegex.ReplaceAllString(url, "https:")
+}
+
 func SimplifyURLs(urls []string) []string {
 	var results = make([]string, len(urls))
 
@@ -56,3 +56,35 @@ func TestSimplify(t *testing.T) {
 		})
 	}
 }
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		title    string
+		input    string
+		expected string
+	}{
+		{
+			title:    "plain url",
+			input:    "https://github.com/stackrox/kernel-packer",
+			expected: "https://github.com/stackrox/kernel-packer",
+		},
+		{
+			title:    "prefixed url",
+			input:    "tag:https://github.com/stackrox/kernel-packer",
+			expected: "https://github.com/stackrox/kernel-packer",
+		},
+		{
+			title:    "http url",
+			input:    "http://dist.kope.io/kernel",
+			expected: "http://dist.kope.io/kernel",
+		},
+	}
+
+	for index, test := range tests {
+		name := fmt.Sprintf("%d %s", index+1, test.title)
+		t.Run(name, func(t *testing.T) {
+			actual := NormalizeURL(test.input)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}